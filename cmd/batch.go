@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/harrybrwn/yt/youtube"
+)
+
+// batch is a list of videos and playlists read from a batch file. Channels
+// are stored with the playlists because they are downloaded as the
+// playlist of their uploads.
+type batch struct {
+	videos    []string
+	playlists []*youtube.URL
+	// starts holds the start times given by video links
+	starts map[string]time.Duration
+	// infoFiles holds the videos that are saved info files
+	infoFiles map[string]bool
+}
+
+// readBatchFile reads a batch file. A name of "-" reads from stdin.
+func readBatchFile(name string, stdin io.Reader) (*batch, error) {
+	if name == "-" {
+		return readBatch(stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readBatch(f)
+}
+
+// readBatch reads one url or id per line ignoring blank lines and lines
+// that start with '#' or ';'.
+func readBatch(r io.Reader) (*batch, error) {
+	var (
+		b    = &batch{}
+		sc   = bufio.NewScanner(r)
+		line int
+	)
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+		if err := b.add(text); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return b, sc.Err()
+}
+
+// add adds a url or id to the batch. Links to a video in a playlist are
+// treated as videos.
+func (b *batch) add(arg string) error {
+	u, err := youtube.ParseURL(arg)
+	if err != nil {
+		return err
+	}
+	if u.IsVideo() {
+		b.addVideo(u)
+	} else {
+		b.playlists = append(b.playlists, u)
+	}
+	return nil
+}
+
+// addList adds a url or id to the batch preferring playlists over videos
+// for links to a video in a playlist.
+func (b *batch) addList(arg string) error {
+	u, err := youtube.ParseURL(arg)
+	if err != nil {
+		return err
+	}
+	if u.IsPlaylist() || u.IsChannel() {
+		b.playlists = append(b.playlists, u)
+	} else {
+		b.addVideo(u)
+	}
+	return nil
+}
+
+func (b *batch) addVideo(u *youtube.URL) {
+	b.videos = append(b.videos, u.VideoID)
+	if u.Start > 0 {
+		if b.starts == nil {
+			b.starts = make(map[string]time.Duration)
+		}
+		b.starts[u.VideoID] = u.Start
+	}
+}
+
+// addInfoFile adds a saved info file that is downloaded from instead of
+// looking the video up on youtube.
+func (b *batch) addInfoFile(name string) {
+	b.videos = append(b.videos, name)
+	if b.infoFiles == nil {
+		b.infoFiles = make(map[string]bool)
+	}
+	b.infoFiles[name] = true
+}
+
+// lookup returns a videoLookup that loads the batch's info files and uses
+// fn for every other video.
+func (b *batch) lookup(c *youtube.Client, fn videoLookup) videoLookup {
+	return func(id string) (*youtube.Video, error) {
+		if b.infoFiles[id] {
+			return c.LoadInfo(id)
+		}
+		return fn(id)
+	}
+}
+
+// lookupPlaylist gets a playlist or the uploads playlist of a channel.
+func lookupPlaylist(c *youtube.Client, u *youtube.URL) (*youtube.Playlist, error) {
+	if u.IsPlaylist() {
+		return c.NewPlaylist(u.PlaylistID)
+	}
+	return c.ChannelPlaylist(u.Channel())
+}
+
+// listID returns the id of a playlist or the channel whose uploads are
+// downloaded.
+func listID(u *youtube.URL) string {
+	if u.IsPlaylist() {
+		return u.PlaylistID
+	}
+	return u.Channel()
+}
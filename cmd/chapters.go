@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/harrybrwn/yt/pkg/ffmpeg"
+	"github.com/harrybrwn/yt/youtube"
+)
+
+var chapterExts = map[string]bool{
+	".mp4": true,
+	".m4a": true,
+	".m4v": true,
+	".mov": true,
+}
+
+// splitChapters cuts a downloaded file into one file per chapter and
+// removes the original. The chapter files are named after the video
+// and the chapter title.
+func splitChapters(v *youtube.Video, file string) ([]string, error) {
+	if len(v.Chapters) == 0 {
+		return nil, nil
+	}
+	if !ffmpeg.Available() {
+		return nil, ffmpeg.ErrNotFound
+	}
+	ext := filepath.Ext(file)
+	base := strings.TrimSuffix(file, ext)
+	files := make([]string, 0, len(v.Chapters))
+	for i, c := range v.Chapters {
+		name := fmt.Sprintf("%s - %02d %s%s", base, i+1, c.FileName, ext)
+		if err := ffmpeg.Cut(file, name, c.Start, c.End); err != nil {
+			return files, err
+		}
+		files = append(files, name)
+	}
+	return files, os.Remove(file)
+}
+
+// embedChapters writes the video's chapters into the file's metadata. Files
+// that do not support chapter atoms are left untouched.
+func embedChapters(v *youtube.Video, file string) error {
+	if len(v.Chapters) == 0 || !chapterExts[filepath.Ext(file)] || !ffmpeg.Available() {
+		return nil
+	}
+	chapters := make([]ffmpeg.Chapter, len(v.Chapters))
+	for i, c := range v.Chapters {
+		chapters[i] = ffmpeg.Chapter{Title: c.Title, Start: c.Start, End: c.End}
+	}
+	return ffmpeg.WriteChapters(file, chapters)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harrybrwn/yt/pkg/ffmpeg"
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/pflag"
+)
+
+// rangeOptions holds the options for downloading part of a video.
+type rangeOptions struct {
+	start, end string
+}
+
+func (ro *rangeOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&ro.start, "start", "", "Only download the video from this time (\"1:30\", \"90\" or \"1m30s\")")
+	flags.StringVar(&ro.end, "end", "", "Only download the video until this time")
+}
+
+// clipRange returns the start and end of the clip to download for a video.
+// The start of a link's "t" parameter is used when there is no --start
+// flag. Returns false if the whole video should be downloaded.
+func (ro *rangeOptions) clipRange(v *youtube.Video, starts map[string]time.Duration) (start, end time.Duration, ok bool, err error) {
+	if ro.start != "" {
+		if start, err = parseClipTime(ro.start); err != nil {
+			return 0, 0, false, err
+		}
+	} else {
+		start = starts[v.ID]
+	}
+	if ro.end != "" {
+		if end, err = parseClipTime(ro.end); err != nil {
+			return 0, 0, false, err
+		}
+		if end <= start {
+			return 0, 0, false, fmt.Errorf("end time %s is not after the start time %s", end, start)
+		}
+	}
+	return start, end, start > 0 || end > 0, nil
+}
+
+// parseClipTime parses a time in a video given as "hh:mm:ss", "mm:ss", a
+// number of seconds or a duration such as "1m30s".
+func parseClipTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil && strings.ContainsAny(s, "hms") {
+		if d < 0 {
+			return 0, fmt.Errorf("negative time %q", s)
+		}
+		return d, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var total float64
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil || n < 0 || (i < len(parts)-1 && strings.Contains(p, ".")) {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		total = total*60 + n
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// downloadClip downloads the part of a video between start and end. Only
+// the segments of the streams that cover the clip are downloaded which are
+// then trimmed precisely with ffmpeg. An end of zero downloads until the
+// end of the video. The video stream, or the audio stream for audio clips,
+// is returned. The downloads are reported as the video's progress.
+func downloadClip(v *youtube.Video, fname string, start, end time.Duration, audio bool, dl *downloadOptions, status *videoStatus) (*youtube.Stream, error) {
+	if v.IsLive {
+		return nil, errors.New("cannot download part of a live stream")
+	}
+	var streams []*youtube.Stream
+	if s := v.BestAudioStream(); s != nil {
+		streams = append(streams, s)
+	}
+	if !audio {
+		if len(v.VideoStreams) > 0 && len(streams) > 0 {
+			streams = append([]*youtube.Stream{bestIndexedStream(v.VideoStreams)}, streams...)
+		} else {
+			streams = []*youtube.Stream{v.BestStream()}
+		}
+	}
+	if len(streams) == 0 {
+		return nil, errors.New("no audio streams")
+	}
+	precise := ffmpeg.Available()
+	if !precise && len(streams) > 1 {
+		return nil, errors.New("ffmpeg is needed to download part of a video")
+	}
+
+	var inputs []ffmpeg.Input
+	defer func() {
+		for _, in := range inputs {
+			os.Remove(in.File)
+		}
+	}()
+	for i, s := range streams {
+		tmp := fmt.Sprintf("%s.part%d", fname, i)
+		if !precise && (s.InitRange == nil || s.IndexRange == nil) {
+			return nil, errors.New("ffmpeg is needed to cut streams without an index")
+		}
+		var offset time.Duration
+		err := dl.retry(func() error {
+			file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			// the size of the clip is not known until its index is read
+			offset, err = s.WriteRange(status.writer(file, 0), start, end)
+			if err == youtube.ErrNoIndex {
+				// fall back to the whole stream
+				total, _ := strconv.ParseInt(s.ContentLength, 10, 64)
+				offset = 0
+				_, err = s.WriteTo(status.writer(file, total))
+			}
+			if e := file.Close(); err == nil {
+				err = e
+			}
+			return err
+		})
+		if err != nil {
+			os.Remove(tmp)
+			return nil, err
+		}
+		inputs = append(inputs, ffmpeg.Input{File: tmp, Start: start - offset})
+	}
+	if !precise {
+		// without ffmpeg the clip starts and ends on a segment boundary
+		return streams[0], os.Rename(inputs[0].File, fname)
+	}
+	var duration time.Duration
+	if end > 0 {
+		duration = end - start
+	}
+	return streams[0], ffmpeg.Trim(inputs, fname, duration)
+}
+
+// clipChapters returns a copy of the video with its chapters cut to the
+// part between start and end and moved to start at zero so that they line
+// up with a downloaded clip. An end of zero is the end of the video.
+func clipChapters(v *youtube.Video, start, end time.Duration) *youtube.Video {
+	clip := *v
+	clip.Chapters = nil
+	for _, c := range v.Chapters {
+		if (end > 0 && c.Start >= end) || (c.End > 0 && c.End <= start) {
+			continue
+		}
+		if c.Start < start {
+			c.Start = start
+		}
+		if end > 0 && (c.End == 0 || c.End > end) {
+			c.End = end
+		}
+		c.Start -= start
+		if c.End > 0 {
+			c.End -= start
+		}
+		clip.Chapters = append(clip.Chapters, c)
+	}
+	return &clip
+}
+
+// bestIndexedStream returns the highest quality stream that has an index
+// so that only part of it needs to be downloaded. Falls back to the
+// highest quality stream when none of them have an index.
+func bestIndexedStream(streams []youtube.Stream) *youtube.Stream {
+	var best *youtube.Stream
+	for i, s := range streams {
+		if s.IndexRange == nil {
+			continue
+		}
+		if best == nil || s.Height > best.Height || (s.Height == best.Height && s.Bitrate > best.Bitrate) {
+			best = &streams[i]
+		}
+	}
+	if best == nil {
+		return youtube.GetBestStream(streams)
+	}
+	return best
+}
@@ -1,15 +1,34 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/harrybrwn/yt/pkg/ffmpeg"
+	"github.com/harrybrwn/yt/pkg/terminal"
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 func TestMakeCommand(t *testing.T) {
-	c := newDownloadCommand("test", "test command", ".txt")
+	c := newDownloadCommand(&options{}, "test", "test command", ".txt")
 	if c.Use != "test [ids...]" {
 		t.Error("wrong usage message")
 	}
@@ -20,17 +39,18 @@ func TestMakeCommand(t *testing.T) {
 	if ext != ".txt" {
 		t.Errorf("wrong default extension: got: '%s'; want '.txt'", ext)
 	}
+	requireNetwork(t)
 	if err := c.RunE(c, []string{"fR2xOh8CqMM"}); err == nil {
 		t.Error("expected error")
 	}
 
-	if err := redirectPath(t, func(t *testing.T) {
-		c = newDownloadCommand("video", "test videos", ".mp4")
+	if err := redirectPath(t, func(t *testing.T, opts *options) {
+		c = newDownloadCommand(opts, "video", "test videos", ".mp4")
 		if err := c.RunE(c, []string{"fR2xOh8CqMM", "O9Ks3_8Nq1s"}); err != nil {
 			t.Error("run failed", err)
 		}
 
-		c = newDownloadCommand("audio", "test videos", ".mpa")
+		c = newDownloadCommand(opts, "audio", "test videos", ".mpa")
 		if err := c.RunE(c, []string{"fR2xOh8CqMM", "O9Ks3_8Nq1s"}); err != nil {
 			t.Error("run failed", err)
 		}
@@ -40,8 +60,10 @@ func TestMakeCommand(t *testing.T) {
 }
 
 func TestDownloadPlaylist(t *testing.T) {
-	if err := redirectPath(t, func(t *testing.T) {
-		err := playlistCmd.RunE(playlistCmd, []string{"PLo7FOXNe7Yt9U0Qh1KBDjHQUuQ5BQR9Jt"})
+	requireNetwork(t)
+	if err := redirectPath(t, func(t *testing.T, opts *options) {
+		c := newPlaylistCmd(opts)
+		err := c.RunE(c, []string{"PLo7FOXNe7Yt9U0Qh1KBDjHQUuQ5BQR9Jt"})
 		if err != nil {
 			t.Error(err)
 		}
@@ -50,42 +72,38 @@ func TestDownloadPlaylist(t *testing.T) {
 	}
 }
 
-func redirectPath(t *testing.T, fn func(t *testing.T)) error {
-	var err error
-	pathCopy := path
+// requireNetwork skips tests that download from youtube unless the
+// YT_NETWORK_TESTS environment variable is set. Failed downloads are
+// errors so these tests cannot pass without a connection.
+func requireNetwork(t *testing.T) {
+	t.Helper()
+	if testing.Short() || os.Getenv("YT_NETWORK_TESTS") == "" {
+		t.Skip("set YT_NETWORK_TESTS to run tests that download from youtube")
+	}
+}
 
+func redirectPath(t *testing.T, fn func(t *testing.T, opts *options)) error {
+	var err error
 	baseTestPath := filepath.Join(
 		os.TempDir(),
 		fmt.Sprintf("yt_cmd_tests%d", time.Now().UnixNano()),
 	)
 	testDIR := filepath.Join(baseTestPath, "TESTS")
-	path = testDIR
-	cwd = testDIR
 
-	if _, err = os.Stat(path); os.IsNotExist(err) {
-		err = os.MkdirAll(path, 0755)
+	if _, err = os.Stat(testDIR); os.IsNotExist(err) {
+		err = os.MkdirAll(testDIR, 0755)
 		if err != nil {
 			return err
 		}
 	}
 
-	fn(t)
+	fn(t, &options{path: testDIR})
 
 	err = os.RemoveAll(testDIR)
 	if err != nil {
 		return err
 	}
-	err = os.Remove(baseTestPath)
-	if err != nil {
-		return err
-	}
-
-	cwd, err = os.Getwd()
-	if err != nil {
-		return err
-	}
-	path = pathCopy
-	return nil
+	return os.Remove(baseTestPath)
 }
 
 func TestRootRun(t *testing.T) {
@@ -96,28 +114,1467 @@ func TestRootRun(t *testing.T) {
 	}
 }
 
+func TestRootCommandsIndependent(t *testing.T) {
+	a, b := RootCommand(), RootCommand()
+	if err := a.PersistentFlags().Set("path", "/videos"); err != nil {
+		t.Fatal(err)
+	}
+	if p := b.PersistentFlags().Lookup("path").Value.String(); p != "" {
+		t.Errorf("setting one root's path changed another's to %q", p)
+	}
+	for _, c := range b.Commands() {
+		if c.Parent() != b {
+			t.Errorf("%s command is shared between root commands", c.Name())
+		}
+	}
+}
+
 func TestUtils(t *testing.T) {
 	tests := []struct {
 		url, id string
 	}{
 		{"https://www.youtube.com/watch?v=kJQP7kiw5Fk", "kJQP7kiw5Fk"},
 		{"https://www.youtube.com/watch?v=HaeH6KYCcmM", "HaeH6KYCcmM"},
-		// {"https://www.youtube.com/playlist?list=PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi", "PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi"},
+		{"https://youtu.be/HaeH6KYCcmM?t=10", "HaeH6KYCcmM"},
+		{"HaeH6KYCcmM", "HaeH6KYCcmM"},
 	}
 	for _, tst := range tests {
-		if !isurl(tst.url) {
-			t.Errorf("%s should be detected as a url", tst.url)
+		id, err := videoID(tst.url)
+		if err != nil {
+			t.Errorf("%s should be detected as a url: %v", tst.url, err)
 		}
-		if id := getid(tst.url); id != tst.id {
+		if id != tst.id {
 			t.Errorf("got wrong id (%s) from url %s", id, tst.url)
 		}
 	}
-	url := "https://www.youtube.com/watch?v=kJQP7kiw5Fk"
+	for _, url := range []string{
+		"https://www.youtube.com/playlist?list=PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi",
+		"https://example.com/watch?v=kJQP7kiw5Fk",
+		"missing.info.json",
+	} {
+		if _, err := videoID(url); err == nil {
+			t.Errorf("%s is not a video url", url)
+		}
+	}
+
+	// saved info files are found by whether they exist, not by their name
+	file, err := ioutil.TempFile("", "meta-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	if id, err := videoID(file.Name()); err != nil || id != file.Name() {
+		t.Errorf("got %q, %v for an info file", id, err)
+	}
+}
+
+func TestParseThumbSize(t *testing.T) {
+	tests := []struct {
+		size string
+		w, h int
+		err  bool
+	}{
+		{"max", 0, 0, false},
+		{"medium", 320, 180, false},
+		{"640x480", 640, 480, false},
+		{"640X480", 640, 480, false},
+		{"big", 0, 0, true},
+		{"12xab", 0, 0, true},
+	}
+	for _, tt := range tests {
+		w, h, err := parseThumbSize(tt.size)
+		if (err != nil) != tt.err {
+			t.Errorf("parseThumbSize(%q): unexpected error value %v", tt.size, err)
+			continue
+		}
+		if w != tt.w || h != tt.h {
+			t.Errorf("parseThumbSize(%q): got %dx%d, want %dx%d", tt.size, w, h, tt.w, tt.h)
+		}
+	}
+}
+
+func TestInfoFormatting(t *testing.T) {
+	durations := map[time.Duration]string{
+		0:                                 "0:00",
+		59 * time.Second:                  "0:59",
+		5*time.Minute + 3*time.Second:     "5:03",
+		2*time.Hour + 4*time.Minute + 9e9: "2:04:09",
+	}
+	for d, want := range durations {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v): got %q, want %q", d, got, want)
+		}
+	}
+	counts := map[string]string{
+		"":           "",
+		"12":         "12",
+		"1234":       "1,234",
+		"123456":     "123,456",
+		"1234567890": "1,234,567,890",
+		"abc":        "abc",
+	}
+	for n, want := range counts {
+		if got := formatCount(n); got != want {
+			t.Errorf("formatCount(%q): got %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestReadBatch(t *testing.T) {
+	input := `# a comment
+https://www.youtube.com/watch?v=kJQP7kiw5Fk
+
+; another comment
+HaeH6KYCcmM
+https://www.youtube.com/playlist?list=PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi
+PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo
+https://www.youtube.com/channel/UCBR8-60-B28hp2BmDPdntcQ
+https://www.youtube.com/@YouTube
+https://www.youtube.com/user/someone/videos
+`
+	b, err := readBatch(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	videos := []string{"kJQP7kiw5Fk", "HaeH6KYCcmM"}
+	lists := []youtube.URL{
+		{PlaylistID: "PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi"},
+		{PlaylistID: "PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo"},
+		{ChannelID: "UCBR8-60-B28hp2BmDPdntcQ"},
+		{Handle: "@YouTube"},
+		{Handle: "user/someone"},
+	}
+	if !reflect.DeepEqual(b.videos, videos) {
+		t.Errorf("wrong videos: got %v, want %v", b.videos, videos)
+	}
+	if len(b.playlists) != len(lists) {
+		t.Fatalf("wrong number of playlists: got %d, want %d", len(b.playlists), len(lists))
+	}
+	for i, u := range b.playlists {
+		if *u != lists[i] {
+			t.Errorf("wrong playlist: got %+v, want %+v", *u, lists[i])
+		}
+	}
+
+	if _, err = readBatch(strings.NewReader("kJQP7kiw5Fk\nhttps://example.com\n")); err == nil {
+		t.Error("expected an error for an invalid line")
+	}
+}
+
+func TestParseClipTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90", 90 * time.Second},
+		{"1:30", 90 * time.Second},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+		{"1:30.5", 90*time.Second + 500*time.Millisecond},
+		{"1m30s", 90 * time.Second},
+		{"2h", 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := parseClipTime(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	for _, s := range []string{"", "abc", "1:2:3:4", "1.5:30", "-5", "-1m"} {
+		if _, err := parseClipTime(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := &config{}
+	if err := yaml.Unmarshal([]byte("path: /videos\nretries: 5\naudio:\n  path: /music\n  format: worst\n"), &c.values); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.get("audio.path"); !ok || v != "/music" {
+		t.Errorf("got %q for audio.path", v)
+	}
+	if _, ok := c.get("audio"); ok {
+		t.Error("sections should not have a value")
+	}
+	c.set("video.extension", ".mkv")
+	c.set("concurrency", "2")
+	if v, _ := c.get("video.extension"); v != ".mkv" {
+		t.Errorf("got %q for video.extension", v)
+	}
+	if v, _ := lookup(c.values, "concurrency"); v != 2 {
+		t.Errorf("numbers should be stored as numbers, got %#v", v)
+	}
+	if !c.unset("video.extension") || c.unset("video.extension") {
+		t.Error("wrong result from unset")
+	}
+	if _, ok := lookup(c.values, "video"); ok {
+		t.Error("empty sections should be removed")
+	}
+
+	os.Setenv("YT_AUDIO_RETRIES", "7")
+	defer os.Unsetenv("YT_AUDIO_RETRIES")
+	cmd := &cobra.Command{Use: "audio"}
+	cmd.Flags().String("path", "", "")
+	cmd.Flags().String("format", "best", "")
+	cmd.Flags().Int("retries", 0, "")
+	cmd.Flags().String("output", "", "")
+	if err := cmd.Flags().Parse([]string{"--format", "720p"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.apply(cmd); err != nil {
+		t.Fatal(err)
+	}
+	for flag, want := range map[string]string{
+		"path":    "/music",
+		"format":  "720p",
+		"retries": "7",
+		"output":  "",
+	} {
+		if got := cmd.Flags().Lookup(flag).Value.String(); got != want {
+			t.Errorf("%s: got %q, want %q", flag, got, want)
+		}
+	}
+}
+
+func TestSelectStream(t *testing.T) {
+	v := &youtube.Video{
+		Streams: youtube.Streams{
+			{ITag: 18, Height: 360, Width: 640, QualityLabel: "360p"},
+			{ITag: 22, Height: 720, Width: 1280, QualityLabel: "720p"},
+		},
+		AudioStreams: youtube.AudioStreams{{ITag: 140, Bitrate: 128}, {ITag: 251, Bitrate: 160}},
+	}
+	tests := []struct {
+		format string
+		audio  bool
+		itag   int
+	}{
+		{"best", false, 22},
+		{"worst", false, 18},
+		{"360p", false, 18},
+		{"22", false, 22},
+		{"best", true, 251},
+		{"worst", true, 140},
+		{"140", false, 140},
+	}
+	for _, tt := range tests {
+		s, err := selectStream(v, tt.format, tt.audio)
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		if s.ITag != tt.itag {
+			t.Errorf("%s: got itag %d, want %d", tt.format, s.ITag, tt.itag)
+		}
+	}
+	if _, err := selectStream(v, "1080p", false); err == nil {
+		t.Error("expected an error for a missing quality")
+	}
+}
+
+func TestSelectStreamDASH(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `<MPD mediaPresentationDuration="PT4S"><Period><AdaptationSet mimeType="video/mp4">
+<Representation id="137" height="1080"><SegmentList><SegmentURL media="seg/1"/></SegmentList></Representation>
+</AdaptationSet></Period></MPD>`)
+	}))
+	defer srv.Close()
+	v := &youtube.Video{
+		Streams:         youtube.Streams{{ITag: 22, URL: srv.URL + "/22", QualityLabel: "720p"}},
+		DASHManifestURL: srv.URL + "/manifest",
+	}
+	if s, err := selectStream(v, "22", false); err != nil || s.ITag != 22 {
+		t.Fatalf("got %+v, %v", s, err)
+	}
+	if requests != 0 {
+		t.Error("the manifest should not be requested when the stream can be downloaded")
+	}
+	s, err := selectStream(v, "137", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ITag != 137 || !s.Segmented() || requests != 1 {
+		t.Errorf("expected the stream from the manifest, got %+v after %d requests", s, requests)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]int64{
+		"":        0,
+		"1000":    1000,
+		"500K":    500 * 1024,
+		"2M":      2 << 20,
+		"1.5MiB":  3 << 19,
+		"2mb/s":   2 << 20,
+		"1G":      1 << 30,
+		" 64KB  ": 64 << 10,
+	}
+	for in, want := range tests {
+		got, err := parseRate(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+		} else if got != want {
+			t.Errorf("%q: got %d, want %d", in, got, want)
+		}
+	}
+	for _, in := range []string{"fast", "-2M", "M", "0"} {
+		if _, err := parseRate(in); err == nil {
+			t.Errorf("expected an error for %q", in)
+		}
+	}
+}
+
+func TestProgressEvents(t *testing.T) {
+	var out bytes.Buffer
+	dl := &downloadOptions{progress: "json"}
+	term, err := dl.setup(&options{}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := newStatusBoard(term, dl.events, nil)
+	dir, err := ioutil.TempDir("", "yt-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "video.mp4")
+
+	status := board.add("https://youtu.be/abc")
+	v := &youtube.Video{}
+	v.ID, v.Title = "abc", "A Video"
+	status.fetched(v)
+	status.start(file)
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(status.writer(f, 5), "12345")
+	f.Close()
+	status.completed()
+	board.add("def").failed(&youtube.IntegrityError{Reason: "got 1 of 2 bytes"})
+	board.stop()
+
+	var events []event
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var e event
+		if err = dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	want := []string{"queued", "metadata-fetched", "started", "progress", "completed", "queued", "failed"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got events %v, want %v", names, want)
+	}
+	if e := events[3]; e.Bytes != 5 || e.Total != 5 || e.ID != "abc" {
+		t.Errorf("bad progress event %+v", e)
+	}
+	if e := events[4]; e.File != file || e.Bytes != 5 || e.Title != "A Video" {
+		t.Errorf("bad completed event %+v", e)
+	}
+	if e := events[6]; e.ID != "def" || e.ErrorType != "integrity" || e.Error == "" {
+		t.Errorf("bad failed event %+v", e)
+	}
+
+	if _, err = (&downloadOptions{progress: "xml"}).setup(&options{}, &out); err == nil {
+		t.Error("expected an error for an unknown progress format")
+	}
+}
+
+func TestRunResults(t *testing.T) {
+	run := func(results ...error) error {
+		board := newStatusBoard(terminal.Plain(ioutil.Discard), nil, nil)
+		for i, err := range results {
+			s := board.add(fmt.Sprint(i))
+			if err != nil {
+				s.failed(err)
+			} else {
+				s.completed()
+			}
+		}
+		board.add("skipped").skip("filtered")
+		return board.finish()
+	}
+	failure := errors.New("failed")
+	if err := run(nil, nil); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	for _, tt := range []struct {
+		results []error
+		code    int
+	}{
+		{[]error{nil, failure}, exitPartialFailure},
+		{[]error{failure, failure}, exitTotalFailure},
+	} {
+		err := run(tt.results...)
+		e, ok := err.(*runError)
+		if !ok {
+			t.Fatalf("expected a run error, got %v", err)
+		}
+		if e.code != tt.code {
+			t.Errorf("got exit code %d, want %d", e.code, tt.code)
+		}
+	}
+
+	board := newStatusBoard(terminal.Plain(ioutil.Discard), nil, nil)
+	board.add("abc").failed(failure)
+	if err := board.finish(); err.Error() != "failed" {
+		t.Errorf("a single video should return its own error, got %v", err)
+	}
+}
+
+func TestSelectItems(t *testing.T) {
+	for _, tt := range []struct {
+		opts itemOptions
+		want []int
+	}{
+		{itemOptions{start: 1}, []int{1, 2, 3, 4, 5, 6, 7, 8}},
+		{itemOptions{start: 1, items: "1-3,5,7-"}, []int{1, 2, 3, 5, 7, 8}},
+		{itemOptions{start: 2, end: 4}, []int{2, 3, 4}},
+		{itemOptions{start: 3, items: "1-4"}, []int{3, 4}},
+		{itemOptions{start: 1, end: 3, reverse: true}, []int{3, 2, 1}},
+		{itemOptions{start: 1, items: "10-"}, nil},
+		// the order of --items is kept and entries are only included once
+		{itemOptions{start: 1, items: "5,1-3"}, []int{5, 1, 2, 3}},
+		{itemOptions{start: 1, items: "7-,2,8,2"}, []int{7, 8, 2}},
+		{itemOptions{start: 1, items: "5,1-3", reverse: true}, []int{3, 2, 1, 5}},
+	} {
+		got, err := tt.opts.selectItems(8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+
+	random := &itemOptions{start: 1, random: true}
+	got, err := random.selectItems(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]bool)
+	for _, i := range got {
+		seen[i] = true
+	}
+	if len(got) != 20 || len(seen) != 20 {
+		t.Errorf("random order should have every item once: %v", got)
+	}
+
+	for _, items := range []string{"0", "a-b", "5-2", "1,,2", "-3"} {
+		if _, err := parseItems(items); err == nil {
+			t.Errorf("expected an error for %q", items)
+		}
+	}
+	for _, opts := range []itemOptions{{start: 0}, {start: 5, end: 2}, {start: 1, random: true, reverse: true}} {
+		if err := opts.validate(); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	v := &youtube.Video{}
+	v.ID, v.Title, v.Author = "abc", "A #shorts video", "someone"
+	v.LengthSeconds, v.ViewCount = "45", "1200"
+	v.IsLiveContent = true
+	v.Keywords = []string{"Music", "live"}
+	v.PublishDate = "2020-05-01"
+
+	for _, tt := range []struct {
+		expr  string
+		match bool
+	}{
+		{"duration < 1m", true},
+		{"duration >= 1:00", false},
+		{"views > 1000 & views <= 1200", true},
+		{"title ~= '(?i)#SHORTS'", true},
+		{"title !~ shorts", false},
+		{"!is_live & was_live", true},
+		{"is_live == false", true},
+		{"is_live || duration > 3600", false},
+		{"(is_live | was_live) && author == someone", true},
+		{"keywords == music", true},
+		{"keywords != music", false},
+		{"keywords ~= ^li", true},
+		{"upload_date >= 20200101 & upload_date < 2020-06-01", true},
+		{"filesize < 10M", false},
+		{"filesize <? 10M", true},
+		{"playlist_index", false},
+		{`title == "A #shorts video"`, true},
+	} {
+		f, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := f.match(videoFields(v, 0)); got != tt.match {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.match)
+		}
+	}
+
+	for _, expr := range []string{
+		"", "duration <", "size > 1", "duration > abc", "is_live < 1",
+		"(duration > 1", "duration > 1 duration", "title ~= '(' ", `title == "x`,
+	} {
+		if _, err := parseFilter(expr); err == nil {
+			t.Errorf("expected an error for %q", expr)
+		}
+	}
+
+	fo := &filterOptions{minDuration: "1m", rejectTitle: "(?i)shorts"}
+	if err := fo.compile(); err != nil {
+		t.Fatal(err)
+	}
+	err := fo.check(v, "best", false)
+	skip, ok := err.(*skipError)
+	if !ok || skip.reason != "shorter than 1m" {
+		t.Errorf("expected video to be skipped for being short, got %v", err)
+	}
+	fo = &filterOptions{dateAfter: "2021-01-01"}
+	if err = fo.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if err = fo.check(v, "best", false); err == nil {
+		t.Error("expected video to be skipped by date")
+	}
+	if err = (&filterOptions{maxFilesize: "big"}).compile(); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+}
+
+func TestSyncState(t *testing.T) {
+	root, err := ioutil.TempDir("", "yt-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	target := &target{downloadOptions: &downloadOptions{}, term: terminal.Plain(ioutil.Discard)}
+
+	st, err := loadMirror(root, "PL1", "Old Title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(st.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for id, file := range map[string]string{"a": "a.mp4", "b": "b.mp4", "c": "c.mp4"} {
+		if err = ioutil.WriteFile(st.path(file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err = st.set(id, &syncEntry{File: file, Title: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the mirror is found after the playlist is renamed and then moved
+	st, err = loadMirror(root, "PL1", "New Title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Videos) != 3 || st.dir != filepath.Join(root, "Old Title") {
+		t.Fatalf("did not find the old mirror: %+v", st)
+	}
+	if err = st.rename(root, "New Title", target); err != nil {
+		t.Fatal(err)
+	}
+	if st.dir != filepath.Join(root, "New Title") || !exists(st.path("a.mp4")) {
+		t.Errorf("mirror was not moved to the new title")
+	}
+
+	st.removed("a", st.Videos["a"], target, &syncOptions{})
+	if !st.Videos["a"].Removed || !exists(st.path("a.mp4")) {
+		t.Error("removed videos should be kept by default")
+	}
+	if n := len(st.entries()); n != 2 {
+		t.Errorf("got %d entries, want 2", n)
+	}
+	st.removed("b", st.Videos["b"], target, &syncOptions{remove: true})
+	if _, ok := st.Videos["b"]; ok || exists(st.path("b.mp4")) {
+		t.Error("expected b to be deleted")
+	}
+	st.removed("c", st.Videos["c"], target, &syncOptions{archive: "old"})
+	if _, ok := st.Videos["c"]; ok || exists(st.path("c.mp4")) || !exists(st.path("old/c.mp4")) {
+		t.Error("expected c to be archived")
+	}
+	if err = st.save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := readSyncState(st.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID != "PL1" || saved.Title != "New Title" || len(saved.Videos) != 1 {
+		t.Errorf("wrong state saved: %+v", saved)
+	}
+
+	if err = st.writePlaylistFiles(&playlistFileOptions{formats: []string{"m3u8"}}); err != nil {
+		t.Fatal(err)
+	}
+	// a was removed from the playlist so it is not in the playlist file
+	if m3u, err := ioutil.ReadFile(st.path("New Title.m3u8")); err != nil {
+		t.Error(err)
+	} else if string(m3u) != "#EXTM3U\n#PLAYLIST:New Title\n" {
+		t.Errorf("wrong playlist file:\n%s", m3u)
+	}
+
+	if st, err = loadMirror(root, "PL2", "Other"); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Videos) != 0 || st.dir != filepath.Join(root, "Other") {
+		t.Errorf("expected a new mirror, got %+v", st)
+	}
+}
+
+func TestPlaylistFiles(t *testing.T) {
+	entries := []*syncEntry{
+		{File: "One.mp4", Title: "One", Index: 1, Duration: 61},
+		{File: "sub dir/Two: & more.mp4", Title: "Two\n& more", Index: 2},
+	}
+	var buf bytes.Buffer
+	if err := writeM3U(&buf, "My List", entries); err != nil {
+		t.Fatal(err)
+	}
+	m3u := "#EXTM3U\n#PLAYLIST:My List\n" +
+		"#EXTINF:61,One\nOne.mp4\n" +
+		"#EXTINF:-1,Two & more\nsub dir/Two: & more.mp4\n"
+	if buf.String() != m3u {
+		t.Errorf("wrong m3u playlist:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeXSPF(&buf, "My List", entries); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`<playlist version="1" xmlns="http://xspf.org/ns/0/">`,
+		"<title>My List</title>",
+		"<location>One.mp4</location>",
+		"<duration>61000</duration>",
+		"<location>sub%20dir/Two:%20&amp;%20more.mp4</location>",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("xspf playlist does not contain %q:\n%s", s, buf.String())
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "yt-playlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	po := &playlistFileOptions{formats: []string{"m3u8", "xspf"}}
+	if err = po.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err = po.write(dir, "My List", entries); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(filepath.Join(dir, "My List.m3u8")) || !exists(filepath.Join(dir, "My List.xspf")) {
+		t.Error("playlist files were not written")
+	}
+	po.remove(dir, "My List")
+	if exists(filepath.Join(dir, "My List.m3u8")) || exists(filepath.Join(dir, "My List.xspf")) {
+		t.Error("playlist files were not removed")
+	}
+	if err = (&playlistFileOptions{formats: []string{"pls"}}).validate(); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yt-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	queue := filepath.Join(dir, queueFile)
+	s, err := newServer(dir, queue, "secret", youtube.DefaultClient, &downloadOptions{}, &playlistFileOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	do := func(method, path, token, body string, status int, v interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != status {
+			raw, _ := ioutil.ReadAll(resp.Body)
+			t.Fatalf("%s %s: got status %d, want %d: %s", method, path, resp.StatusCode, status, raw)
+		}
+		if v != nil {
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	do("GET", "/api/jobs", "", "", http.StatusUnauthorized, nil)
+	do("GET", "/api/jobs", "wrong", "", http.StatusUnauthorized, nil)
+	do("POST", "/api/jobs", "secret", `{"url": "https://example.com/watch"}`, http.StatusBadRequest, nil)
+	do("POST", "/api/jobs", "secret", `{"url": "dQw4w9WgXcQ", "filter": "duration <"}`, http.StatusBadRequest, nil)
+	do("POST", "/api/jobs", "secret", `{"url`, http.StatusBadRequest, nil)
+
+	var first, second job
+	do("POST", "/api/jobs", "secret", `{"url": "https://youtu.be/dQw4w9WgXcQ", "audio": true}`, http.StatusCreated, &first)
+	if first.ID == "" || first.State != jobQueued || !first.Audio {
+		t.Errorf("wrong job created: %+v", first)
+	}
+	do("POST", "/api/jobs", "secret", `{"url": "PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi"}`, http.StatusCreated, &second)
+	var jobs []*job
+	do("GET", "/api/jobs?token=secret", "", "", http.StatusOK, &jobs)
+	if len(jobs) != 2 || jobs[0].ID != first.ID {
+		t.Fatalf("wrong jobs listed: %+v", jobs)
+	}
+
+	var canceled job
+	do("DELETE", "/api/jobs/"+first.ID, "secret", "", http.StatusOK, &canceled)
+	if canceled.State != jobCanceled {
+		t.Errorf("job was not canceled: %+v", canceled)
+	}
+	do("DELETE", "/api/jobs/"+first.ID, "secret", "", http.StatusConflict, nil)
+	do("GET", "/api/jobs/nope", "secret", "", http.StatusNotFound, nil)
+
+	// files on the server are never looked up as videos
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err = ioutil.WriteFile("x.info.json", []byte(`{"id": "dQw4w9WgXcQ"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	do("GET", "/api/videos/x.info.json", "secret", "", http.StatusBadRequest, nil)
+	do("GET", "/api/videos/PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi", "secret", "", http.StatusBadRequest, nil)
+
+	// progress from the download events
+	if j := s.next(); j == nil || j.ID != second.ID {
+		t.Fatalf("expected the second job to run next, got %+v", j)
+	}
+	s.update(s.jobs[1], event{Event: eventQueued, ID: "abc"})
+	s.update(s.jobs[1], event{Event: eventStarted, ID: "abc", Title: "A Video", File: filepath.Join(dir, "A Video.mp4")})
+	s.update(s.jobs[1], event{Event: eventProgress, ID: "abc", Bytes: 50, Total: 100})
+	var running job
+	do("GET", "/api/jobs/"+second.ID, "secret", "", http.StatusOK, &running)
+	if running.State != jobRunning || len(running.Videos) != 1 {
+		t.Fatalf("wrong job progress: %+v", running)
+	}
+	if v := running.Videos[0]; v.Title != "A Video" || v.File != "A Video.mp4" || v.Bytes != 50 || v.Total != 100 || v.State != jobRunning {
+		t.Errorf("wrong video progress: %+v", v)
+	}
+
+	for _, name := range []string{"A Video.mp4", "Other.mp4"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(raw)
+	}
+	// only the files of finished videos are served
+	for _, path := range []string{"A%20Video.mp4", "Other.mp4", queueFile, "", "../A%20Video.mp4"} {
+		if status, _ := get("/api/files/" + path + "?token=secret"); status == http.StatusOK {
+			t.Errorf("%q should not be served before it is finished", path)
+		}
+	}
+	s.update(s.jobs[1], event{Event: eventCompleted, ID: "abc", Bytes: 100})
+	if status, body := get("/api/files/A%20Video.mp4?token=secret"); status != http.StatusOK || body != "video" {
+		t.Errorf("could not download the file: %d %q", status, body)
+	}
+	if status, _ := get("/api/files/Other.mp4?token=secret"); status != http.StatusNotFound {
+		t.Errorf("a file that is not in a job should not be served, got %d", status)
+	}
+
+	// running jobs are queued again after a restart
+	if err = s.save(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = newServer(dir, queue, "secret", youtube.DefaultClient, &downloadOptions{}, &playlistFileOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.jobs) != 2 || s.jobs[0].State != jobCanceled || s.jobs[1].State != jobQueued || s.jobs[1].Videos != nil {
+		t.Errorf("wrong jobs loaded: %+v %+v", s.jobs[0], s.jobs[1])
+	}
+}
+
+// blockingWriter is a client that does not read its response.
+type blockingWriter struct {
+	httptest.ResponseRecorder
+	writing chan struct{}
+	unblock chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	close(w.writing)
+	<-w.unblock
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestServerSlowClient(t *testing.T) {
+	s := &server{jobs: []*job{{ID: "abc", State: jobQueued}}}
+	for _, path := range []string{"/api/jobs", "/api/jobs/abc"} {
+		w := &blockingWriter{ResponseRecorder: *httptest.NewRecorder(), writing: make(chan struct{}), unblock: make(chan struct{})}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if path == "/api/jobs" {
+				s.handleJobs(w, httptest.NewRequest("GET", path, nil))
+			} else {
+				s.handleJob(w, httptest.NewRequest("GET", path, nil))
+			}
+		}()
+		<-w.writing
+		locked := make(chan struct{})
+		go func() {
+			s.mu.Lock()
+			s.mu.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("GET %s held the lock while writing the response", path)
+		}
+		close(w.unblock)
+		<-done
+		if !strings.Contains(w.Body.String(), `"id":"abc"`) {
+			t.Errorf("GET %s: wrong response %q", path, w.Body.String())
+		}
+	}
+}
+
+func TestCancelDownload(t *testing.T) {
+	board := newBoard(terminal.Plain(ioutil.Discard), nil, nil)
+	w := board.add("abc").writer(ioutil.Discard, 10)
+	if _, err := w.Write([]byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+	board.cancel()
+	board.cancel()
+	if _, err := w.Write([]byte("12345")); err != errCanceled {
+		t.Errorf("expected the download to be canceled, got %v", err)
+	}
+	dl := &downloadOptions{retries: 3}
+	calls := 0
+	dl.retry(func() error { calls++; return errCanceled })
+	if calls != 1 {
+		t.Errorf("canceled downloads should not be retried, got %d calls", calls)
+	}
+	board.stop()
+}
+
+func TestInterruptCode(t *testing.T) {
+	if code := interruptCode(os.Interrupt); code != 130 {
+		t.Errorf("got exit code %d for an interrupt, want 130", code)
+	}
+	board := newStatusBoard(terminal.Plain(ioutil.Discard), nil, nil)
+	board.stop()
+	board.stop()
+	if _, ok := <-board.quit; ok {
+		t.Error("the board should be stopped")
+	}
+}
+
+// fakeYoutube answers the requests of a youtube.Client so that whole
+// commands can run without a network connection. Every https connection
+// of the client is tunneled to it through a proxy.
+type fakeYoutube struct {
+	titles    map[string]string   // video id -> title
+	playlists map[string][]string // playlist id -> video ids
+	lists     map[string]string   // playlist id -> title
+	broken    map[string]bool     // videos whose streams fail
+	live      map[string]bool     // videos that are live streams
+	indexed   map[string]bool     // videos whose streams have an index
+}
+
+// fakeData is the content of every stream, an mp4 header and some data.
+var fakeData = []byte("\x00\x00\x00\x18ftypmp42 some video data")
+
+// indexedData is the content of the streams of indexed videos, fakeData
+// followed by an index of three 20 second segments and the segments.
+var (
+	fakeSidx    = sidxBox([]uint32{10, 10, 10}, 20)
+	indexedData = append(append(append([]byte{}, fakeData...), fakeSidx...), "aaaaaaaaaabbbbbbbbbbcccccccccc"...)
+)
+
+// sidxBox builds an mp4 segment index with a timescale of one.
+func sidxBox(sizes []uint32, duration uint32) []byte {
+	var body bytes.Buffer
+	binary.Write(&body, binary.BigEndian, struct {
+		Version   uint8
+		Flags     [3]byte
+		RefID     uint32
+		Timescale uint32
+		Earliest  uint32
+		Offset    uint32
+		Reserved  uint16
+		Count     uint16
+	}{RefID: 1, Timescale: 1, Count: uint16(len(sizes))})
+	for _, size := range sizes {
+		binary.Write(&body, binary.BigEndian, [3]uint32{size, duration, 0x90000000})
+	}
+	var box bytes.Buffer
+	binary.Write(&box, binary.BigEndian, uint32(body.Len()+8))
+	box.WriteString("sidx")
+	box.Write(body.Bytes())
+	return box.Bytes()
+}
+
+func (f *fakeYoutube) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/list_ajax":
+		id := r.URL.Query().Get("list")
+		type item struct {
+			ID     string `json:"encrypted_id"`
+			Title  string `json:"title"`
+			Length int    `json:"length_seconds"`
+		}
+		items := []item{}
+		for _, v := range f.playlists[id] {
+			items = append(items, item{v, f.titles[v], 60})
+		}
+		title, ok := f.lists[id]
+		if !ok {
+			title = "List " + id
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"title": title, "video": items})
+	case "/get_video_info":
+		id := r.URL.Query().Get("video_id")
+		title, ok := f.titles[id]
+		if !ok {
+			fmt.Fprint(w, "status=fail&errorcode=100&")
+			return
+		}
+		format := func(itag, height int) map[string]interface{} {
+			m := map[string]interface{}{
+				"itag":          itag,
+				"url":           fmt.Sprintf("https://www.youtube.com/videoplayback?id=%s&itag=%d", id, itag),
+				"mimeType":      `video/mp4; codecs="avc1.42001E, mp4a.40.2"`,
+				"width":         height * 16 / 9,
+				"height":        height,
+				"qualityLabel":  fmt.Sprintf("%dp", height),
+				"contentLength": fmt.Sprint(len(fakeData)),
+			}
+			if f.indexed[id] {
+				m["contentLength"] = fmt.Sprint(len(indexedData))
+				m["initRange"] = map[string]string{"start": "0", "end": fmt.Sprint(len(fakeData) - 1)}
+				m["indexRange"] = map[string]string{"start": fmt.Sprint(len(fakeData)), "end": fmt.Sprint(len(fakeData) + len(fakeSidx) - 1)}
+			}
+			return m
+		}
+		streaming := map[string]interface{}{
+			"formats": []interface{}{format(18, 360), format(22, 720)},
+		}
+		if f.live[id] {
+			streaming["hlsManifestUrl"] = "https://www.youtube.com/hls/" + id + ".m3u8"
+		}
+		resp, _ := json.Marshal(map[string]interface{}{
+			"playabilityStatus": map[string]string{"status": "OK"},
+			"videoDetails": map[string]interface{}{
+				"videoId":       id,
+				"title":         title,
+				"lengthSeconds": "60",
+				"author":        "someone",
+				"isLive":        f.live[id],
+			},
+			"streamingData": streaming,
+		})
+		fmt.Fprintf(w, "status=ok&player_response=%s&", url.QueryEscape(string(resp)))
+	case "/videoplayback":
+		if f.indexed[r.URL.Query().Get("id")] {
+			http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(indexedData))
+			return
+		}
+		if f.broken[r.URL.Query().Get("id")] {
+			// half of a file
+			w.Write(fakeData[:10])
+			return
+		}
+		w.Write(fakeData)
+	default:
+		if id := strings.TrimPrefix(r.URL.Path, "/hls/"); id != r.URL.Path {
+			// a stream that has already ended
+			fmt.Fprintf(w, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2.0,\n/videoplayback?id=%s\n#EXT-X-ENDLIST\n", strings.TrimSuffix(id, ".m3u8"))
+			return
+		}
+		http.NotFound(w, r)
+	}
+}
+
+// client starts the fake and returns a client that talks to it and a
+// function that stops the fake.
+func (f *fakeYoutube) client(t *testing.T) (*youtube.Client, func()) {
+	t.Helper()
+	srv := httptest.NewTLSServer(f)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		dst, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			dst.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			io.Copy(dst, conn)
+			dst.Close()
+		}()
+		io.Copy(conn, dst)
+		conn.Close()
+	}))
+	stop := func() {
+		proxy.Close()
+		srv.Close()
+	}
+	c, err := youtube.NewClient(&youtube.ClientConfig{
+		Proxy: proxy.URL,
+		TLS:   &tls.Config{InsecureSkipVerify: true},
+	})
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	return c, stop
+}
+
+// options returns the options of a command that downloads from the fake
+// into a temporary directory and a function that cleans up after it.
+func (f *fakeYoutube) options(t *testing.T) (*options, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "yt-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, stop := f.client(t)
+	return &options{path: dir, client: c, quiet: true}, func() {
+		stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestVideoCommandPlaylist(t *testing.T) {
+	f := &fakeYoutube{
+		titles:    map[string]string{"aaaaaaaaaaa": "First", "bbbbbbbbbbb": "Second"},
+		playlists: map[string][]string{"PLfake": {"aaaaaaaaaaa", "bbbbbbbbbbb"}},
+	}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	c := newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	if err := c.RunE(c, []string{"https://www.youtube.com/playlist?list=PLfake"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"First.mp4", "Second.mp4"} {
+		b, err := ioutil.ReadFile(filepath.Join(opts.path, "List PLfake", name))
+		if err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(b, fakeData) {
+			t.Errorf("wrong data in %s: %q", name, b)
+		}
+	}
+}
+
+func TestSyncIncompletePlaylist(t *testing.T) {
+	f := &fakeYoutube{
+		titles: map[string]string{
+			"aaaaaaaaaaa": "A", "bbbbbbbbbbb": "B", "ccccccccccc": "C", "ddddddddddd": "D",
+		},
+		playlists: map[string][]string{"PLfake": {"aaaaaaaaaaa", "bbbbbbbbbbb", "ccccccccccc"}},
+	}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	mirror := filepath.Join(opts.path, "List PLfake")
+	sync := func() {
+		t.Helper()
+		c := newSyncCmd(opts)
+		if err := c.Flags().Set("remove", "true"); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.RunE(c, []string{"https://www.youtube.com/playlist?list=PLfake"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(files ...string) {
+		t.Helper()
+		for _, name := range []string{"A.mp4", "B.mp4", "C.mp4", "D.mp4"} {
+			want := false
+			for _, f := range files {
+				want = want || f == name
+			}
+			if got := exists(filepath.Join(mirror, name)); got != want {
+				t.Errorf("%s exists: %v, want %v", name, got, want)
+			}
+		}
+	}
+
+	sync()
+	check("A.mp4", "B.mp4", "C.mp4")
+	// an empty or short list may be missing pages so nothing is removed
+	f.playlists["PLfake"] = nil
+	sync()
+	check("A.mp4", "B.mp4", "C.mp4")
+	f.playlists["PLfake"] = []string{"aaaaaaaaaaa", "bbbbbbbbbbb"}
+	sync()
+	check("A.mp4", "B.mp4", "C.mp4")
+	// a complete list removes the videos that are gone
+	f.playlists["PLfake"] = []string{"aaaaaaaaaaa", "bbbbbbbbbbb", "ddddddddddd"}
+	sync()
+	check("A.mp4", "B.mp4", "D.mp4")
+}
+
+func TestPlaylistDir(t *testing.T) {
+	root := filepath.Join("videos", "root")
+	for title, want := range map[string]string{
+		"Favorites":      "Favorites",
+		"AC/DC":          "ACDC",
+		"../../etc":      "etc",
+		"Vol. 2":         "Vol 2",
+		"..":             "PLfake",
+		" / ":            "PLfake",
+		"C:\\Windows\\x": "CWindowsx",
+	} {
+		dir, err := playlistDir(root, title, "PLfake")
+		if err != nil {
+			t.Errorf("%q: %v", title, err)
+			continue
+		}
+		if dir != filepath.Join(root, want) {
+			t.Errorf("%q: got %s, want %s", title, dir, filepath.Join(root, want))
+		}
+	}
+	if _, err := playlistDir(root, "..", ".."); err == nil {
+		t.Error("expected an error for a playlist without a usable title or id")
+	}
+}
+
+func TestSyncPlaylistTitle(t *testing.T) {
+	f := &fakeYoutube{
+		titles:    map[string]string{"aaaaaaaaaaa": "A"},
+		playlists: map[string][]string{"PLfake": {"aaaaaaaaaaa"}},
+		lists:     map[string]string{"PLfake": "../../outside/list"},
+	}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	c := newSyncCmd(opts)
+	if err := c.RunE(c, []string{"https://www.youtube.com/playlist?list=PLfake"}); err != nil {
+		t.Fatal(err)
+	}
+	mirror := filepath.Join(opts.path, "outsidelist")
+	for _, name := range []string{"A.mp4", syncStateFile, "outsidelist.m3u8"} {
+		if !exists(filepath.Join(mirror, name)) {
+			t.Errorf("%s should be saved in %s", name, mirror)
+		}
+	}
+	if exists(filepath.Join(opts.path, "..", "..", "outside")) {
+		t.Error("the playlist's title should not be able to leave the download directory")
+	}
+}
+
+func TestBrokenConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yt-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(file, []byte("path: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("YT_CONFIG", file)
+	defer os.Unsetenv("YT_CONFIG")
+
+	run := func(args ...string) (string, error) {
+		var out, stderr bytes.Buffer
+		root := RootCommand()
+		root.SetArgs(args)
+		root.SetOut(&out)
+		root.SetErr(&stderr)
+		err := root.Execute()
+		return out.String() + stderr.String(), err
+	}
+	for _, args := range [][]string{{"config", "path"}, {"version"}} {
+		out, err := run(args...)
+		if err != nil {
+			t.Errorf("%v: %v", args, err)
+		}
+		if !strings.Contains(out, "Warning: ignoring the config file") {
+			t.Errorf("%v: expected a warning, got %q", args, out)
+		}
+	}
+	if _, err = run("info", "dQw4w9WgXcQ"); err == nil || !strings.Contains(err.Error(), "config.yaml") {
+		t.Errorf("expected the config error, got %v", err)
+	}
 
-	if !isurl(url) {
-		t.Error("this is a url")
+	// environment variables still apply without the config file
+	defer func(v, b string) { version, builtBy = v, b }(version, builtBy)
+	version, builtBy = "1.0", "someone"
+	os.Setenv("YT_VERSION_VERBOSE", "false")
+	defer os.Unsetenv("YT_VERSION_VERBOSE")
+	out, err := run("version")
+	if err != nil {
+		t.Fatal(err)
 	}
-	if getid(url) != "kJQP7kiw5Fk" {
-		t.Error("got wrong video id")
+	if strings.Contains(out, "built by") {
+		t.Errorf("YT_VERSION_VERBOSE was ignored: %q", out)
+	}
+}
+
+func TestInfoJSONChosenStream(t *testing.T) {
+	f := &fakeYoutube{titles: map[string]string{"aaaaaaaaaaa": "First"}}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	c := newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	for flag, value := range map[string]string{"format": "18", "write-info-json": "true", "embed-chapters": "false"} {
+		if err := c.Flags().Set(flag, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.RunE(c, []string{"aaaaaaaaaaa"}); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filepath.Join(opts.path, "First"+infoExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := youtube.ReadInfo(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Stream == nil || info.Stream.ITag != 18 {
+		t.Errorf("the info file should have the stream chosen by the format, got %+v", info.Stream)
+	}
+}
+
+func TestPlaylistPostprocess(t *testing.T) {
+	f := &fakeYoutube{
+		titles:    map[string]string{"aaaaaaaaaaa": "First"},
+		playlists: map[string][]string{"PLfake": {"aaaaaaaaaaa"}},
+	}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	c := newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	if err := c.Flags().Set("write-info-json", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunE(c, []string{"https://www.youtube.com/playlist?list=PLfake"}); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(filepath.Join(opts.path, "List PLfake", "First"+infoExt)) {
+		t.Error("the postprocessor did not run for a video in a playlist")
+	}
+}
+
+func TestFailedDownloadRemoved(t *testing.T) {
+	f := &fakeYoutube{
+		titles: map[string]string{"aaaaaaaaaaa": "Good", "bbbbbbbbbbb": "Broken"},
+		broken: map[string]bool{"bbbbbbbbbbb": true},
+	}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	c := newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	if err := c.Flags().Set("retries", "0"); err != nil {
+		t.Fatal(err)
+	}
+	err := c.RunE(c, []string{"aaaaaaaaaaa", "bbbbbbbbbbb"})
+	if e, ok := err.(*runError); !ok || e.code != exitPartialFailure {
+		t.Errorf("expected a partial failure, got %v", err)
+	}
+	files, err := ioutil.ReadDir(opts.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "Good.mp4" {
+		for _, f := range files {
+			t.Errorf("found %s", f.Name())
+		}
+		t.Error("only the finished download should be in the directory")
+	}
+}
+
+func TestClipChapters(t *testing.T) {
+	v := &youtube.Video{Chapters: []youtube.Chapter{
+		{Title: "Intro", Start: 0, End: time.Minute},
+		{Title: "Middle", Start: time.Minute, End: 2 * time.Minute},
+		{Title: "End", Start: 2 * time.Minute, End: 3 * time.Minute},
+	}}
+	type span struct {
+		title      string
+		start, end time.Duration
+	}
+	for _, tt := range []struct {
+		start, end time.Duration
+		want       []span
+	}{
+		{30 * time.Second, 90 * time.Second, []span{{"Intro", 0, 30 * time.Second}, {"Middle", 30 * time.Second, time.Minute}}},
+		{70 * time.Second, 80 * time.Second, []span{{"Middle", 0, 10 * time.Second}}},
+		{150 * time.Second, 0, []span{{"End", 0, 30 * time.Second}}},
+		{time.Minute, 2 * time.Minute, []span{{"Middle", 0, time.Minute}}},
+	} {
+		clip := clipChapters(v, tt.start, tt.end)
+		var got []span
+		for _, c := range clip.Chapters {
+			got = append(got, span{c.Title, c.Start, c.End})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v-%v: got %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+	if len(v.Chapters) != 3 || v.Chapters[1].Start != time.Minute {
+		t.Error("the video's own chapters should not change")
+	}
+}
+
+func TestLoadInfoJSON(t *testing.T) {
+	f := &fakeYoutube{titles: map[string]string{"aaaaaaaaaaa": "First"}}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	c := newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	if err := c.Flags().Set("write-info-json", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunE(c, []string{"aaaaaaaaaaa"}); err != nil {
+		t.Fatal(err)
+	}
+	meta := filepath.Join(opts.path, "meta.json")
+	if err := os.Rename(filepath.Join(opts.path, "First"+infoExt), meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(opts.path, "First.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	// the video is no longer on youtube so it has to come from the file
+	delete(f.titles, "aaaaaaaaaaa")
+	c = newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	if err := c.Flags().Set("load-info-json", meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunE(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(filepath.Join(opts.path, "First.mp4")) {
+		t.Error("the video was not downloaded from the info file")
+	}
+
+	// files given as arguments are loaded whatever their name is
+	if err := os.Remove(filepath.Join(opts.path, "First.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	c = newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	if err := c.RunE(c, []string{meta}); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(filepath.Join(opts.path, "First.mp4")) {
+		t.Error("the video was not downloaded from the info file argument")
+	}
+}
+
+func TestConfigLiveExtension(t *testing.T) {
+	f := &fakeYoutube{
+		titles: map[string]string{"aaaaaaaaaaa": "Stream", "bbbbbbbbbbb": "Video"},
+		live:   map[string]bool{"aaaaaaaaaaa": true},
+	}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	c := newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	conf := &config{}
+	conf.set("extension", ".mkv")
+	if err := conf.apply(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunE(c, []string{"aaaaaaaaaaa", "bbbbbbbbbbb"}); err != nil {
+		t.Fatal(err)
+	}
+	// the config sets the default, live streams still get their own
+	for _, name := range []string{"Stream.ts", "Video.mkv"} {
+		if !exists(filepath.Join(opts.path, name)) {
+			t.Errorf("%s was not downloaded", name)
+		}
+	}
+}
+
+func TestClipProgress(t *testing.T) {
+	if ffmpeg.Available() {
+		t.Skip("the fake streams cannot be trimmed by ffmpeg")
+	}
+	f := &fakeYoutube{
+		titles:  map[string]string{"aaaaaaaaaaa": "First"},
+		indexed: map[string]bool{"aaaaaaaaaaa": true},
+	}
+	opts, cleanup := f.options(t)
+	defer cleanup()
+	var out bytes.Buffer
+	c := newDownloadCommand(opts, "video", "youtube videos", ".mp4")
+	c.SetOut(&out)
+	for flag, value := range map[string]string{"start": "25", "progress": "json", "embed-chapters": "false"} {
+		if err := c.Flags().Set(flag, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.RunE(c, []string{"aaaaaaaaaaa"}); err != nil {
+		t.Fatal(err)
+	}
+	var progress bool
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var e event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Event == eventProgress && e.ID == "aaaaaaaaaaa" && e.Bytes > 0 {
+			progress = true
+		}
+	}
+	if !progress {
+		t.Errorf("no progress events for the clip: %s", out.String())
+	}
+	// the header and the last two segments
+	want := string(fakeData) + "bbbbbbbbbbcccccccccc"
+	if b, err := ioutil.ReadFile(filepath.Join(opts.path, "First.mp4")); err != nil {
+		t.Error(err)
+	} else if string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
 	}
 }
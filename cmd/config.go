@@ -0,0 +1,405 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// config is the configuration file holding the default values of flags.
+// Top level keys are flag names which apply to every command and keys in
+// a section named after a command only apply to that command.
+//
+//	path: ~/Videos
+//	concurrency: 2
+//	audio:
+//	  path: ~/Music
+type config struct {
+	file   string
+	values yaml.MapSlice
+}
+
+// configFile returns the name of the config file. The YT_CONFIG
+// environment variable takes precedence over the default location.
+func configFile() (string, error) {
+	if file := os.Getenv("YT_CONFIG"); file != "" {
+		return file, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "yt", "config.yaml"), nil
+}
+
+// loadConfig reads a config file. A missing file is an empty config.
+func loadConfig(file string) (*config, error) {
+	c := &config{file: file}
+	raw, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(raw, &c.values); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return c, nil
+}
+
+func (c *config) save() error {
+	raw, err := yaml.Marshal(c.values)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.file, raw, 0644)
+}
+
+// get finds a value given a key of the form "flag" or "command.flag".
+func (c *config) get(key string) (string, bool) {
+	section, name := splitKey(key)
+	values := c.values
+	if section != "" {
+		v, ok := lookup(values, section)
+		if !ok {
+			return "", false
+		}
+		if values, ok = v.(yaml.MapSlice); !ok {
+			return "", false
+		}
+	}
+	v, ok := lookup(values, name)
+	if !ok {
+		return "", false
+	}
+	if _, isSection := v.(yaml.MapSlice); isSection {
+		return "", false
+	}
+	return configString(v), true
+}
+
+// set sets the value of a key of the form "flag" or "command.flag".
+func (c *config) set(key, value string) {
+	var v interface{} = value
+	// keep numbers and booleans as they are in the file
+	var scalar interface{}
+	if err := yaml.Unmarshal([]byte(value), &scalar); err == nil {
+		switch scalar.(type) {
+		case int, float64, bool:
+			v = scalar
+		}
+	}
+	section, name := splitKey(key)
+	if section == "" {
+		c.values = setItem(c.values, name, v)
+		return
+	}
+	sec, _ := lookup(c.values, section)
+	values, _ := sec.(yaml.MapSlice)
+	c.values = setItem(c.values, section, setItem(values, name, v))
+}
+
+// unset removes a key from the config. Returns false if the key was
+// not set.
+func (c *config) unset(key string) bool {
+	section, name := splitKey(key)
+	if section == "" {
+		var ok bool
+		c.values, ok = removeItem(c.values, name)
+		return ok
+	}
+	v, _ := lookup(c.values, section)
+	values, isSection := v.(yaml.MapSlice)
+	if !isSection {
+		return false
+	}
+	values, ok := removeItem(values, name)
+	if len(values) == 0 {
+		c.values, _ = removeItem(c.values, section)
+	} else {
+		c.values = setItem(c.values, section, values)
+	}
+	return ok
+}
+
+// apply sets the flags of a command that were not given on the command
+// line. Environment variables of the form YT_<COMMAND>_<FLAG> and
+// YT_<FLAG> override the values in the command's section of the config
+// which override the top level values. Flags set from the config are not
+// marked as changed so they still look like defaults to the commands.
+func (c *config) apply(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+		value, ok := c.lookupFlag(cmd.Name(), f.Name)
+		if !ok {
+			return
+		}
+		if e := f.Value.Set(expandHome(value)); e != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, f.Name, e)
+		}
+	})
+	return err
+}
+
+func (c *config) lookupFlag(command, flag string) (string, bool) {
+	if v, ok := os.LookupEnv(envName(command + "_" + flag)); ok {
+		return v, true
+	}
+	if v, ok := os.LookupEnv(envName(flag)); ok {
+		return v, true
+	}
+	if v, ok := c.get(command + "." + flag); ok {
+		return v, true
+	}
+	return c.get(flag)
+}
+
+func envName(name string) string {
+	return "YT_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+func splitKey(key string) (section, name string) {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+func lookup(values yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range values {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func setItem(values yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range values {
+		if fmt.Sprint(item.Key) == key {
+			values[i].Value = value
+			return values
+		}
+	}
+	return append(values, yaml.MapItem{Key: key, Value: value})
+}
+
+func removeItem(values yaml.MapSlice, key string) (yaml.MapSlice, bool) {
+	for i, item := range values {
+		if fmt.Sprint(item.Key) == key {
+			return append(values[:i], values[i+1:]...), true
+		}
+	}
+	return values, false
+}
+
+// configString converts a config value to the string used to set a flag.
+// Lists are joined with commas.
+func configString(v interface{}) string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	parts := make([]string, len(list))
+	for i, item := range list {
+		parts[i] = fmt.Sprint(item)
+	}
+	return strings.Join(parts, ",")
+}
+
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
+
+// knownKey returns an error if the key does not name a flag of any
+// command or of the command named by its section.
+func knownKey(root *cobra.Command, key string) error {
+	section, name := splitKey(key)
+	if section != "" {
+		c, _, err := root.Find(strings.Split(section, "."))
+		if err != nil || c == root {
+			return fmt.Errorf("unknown command %q", section)
+		}
+		if c.Flags().Lookup(name) == nil && c.InheritedFlags().Lookup(name) == nil {
+			return fmt.Errorf("%s has no flag %q", c.Name(), name)
+		}
+		return nil
+	}
+	if root.PersistentFlags().Lookup(name) != nil {
+		return nil
+	}
+	for _, c := range root.Commands() {
+		if c.Flags().Lookup(name) != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no command has a flag %q", name)
+}
+
+func newConfigCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "config",
+		Short: "View and edit the config file",
+		Long: `View and edit the config file holding the default values of flags.
+
+Top level keys set flags for every command and keys in a section named
+after a command, such as "audio.path", only set them for that command.
+Environment variables named YT_<FLAG> or YT_<COMMAND>_<FLAG> override
+the config file and flags override both.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := cmdConfig()
+			if err != nil {
+				return err
+			}
+			if len(c.values) == 0 {
+				cmd.Printf("no config at %s\n", c.file)
+				return nil
+			}
+			raw, err := yaml.Marshal(c.values)
+			if err != nil {
+				return err
+			}
+			cmd.Print(string(raw))
+			return nil
+		},
+	}
+	c.AddCommand(
+		&cobra.Command{
+			Use:   "path",
+			Short: "Print the location of the config file",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				file, err := configFile()
+				if err != nil {
+					return err
+				}
+				cmd.Println(file)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "get <key>",
+			Short: "Print a value from the config file",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				c, err := cmdConfig()
+				if err != nil {
+					return err
+				}
+				v, ok := c.get(args[0])
+				if !ok {
+					return fmt.Errorf("%q is not set", args[0])
+				}
+				cmd.Println(v)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "set <key> <value>",
+			Short: "Set a value in the config file",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := knownKey(cmd.Root(), args[0]); err != nil {
+					return err
+				}
+				c, err := cmdConfig()
+				if err != nil {
+					return err
+				}
+				c.set(args[0], args[1])
+				return c.save()
+			},
+		},
+		&cobra.Command{
+			Use:   "unset <key>",
+			Short: "Remove a value from the config file",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				c, err := cmdConfig()
+				if err != nil {
+					return err
+				}
+				if !c.unset(args[0]) {
+					return fmt.Errorf("%q is not set", args[0])
+				}
+				return c.save()
+			},
+		},
+		&cobra.Command{
+			Use:   "edit",
+			Short: "Open the config file in $EDITOR",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				file, err := configFile()
+				if err != nil {
+					return err
+				}
+				if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					return err
+				}
+				editor := os.Getenv("VISUAL")
+				if editor == "" {
+					editor = os.Getenv("EDITOR")
+				}
+				if editor == "" {
+					editor = "vi"
+				}
+				parts := strings.Fields(editor)
+				if len(parts) == 0 {
+					return errors.New("no editor")
+				}
+				e := exec.Command(parts[0], append(parts[1:], file)...)
+				e.Stdin, e.Stdout, e.Stderr = os.Stdin, os.Stdout, os.Stderr
+				if err = e.Run(); err != nil {
+					return err
+				}
+				// make sure the edited file is still valid
+				_, err = loadConfig(file)
+				return err
+			},
+		},
+	)
+	return c
+}
+
+// configOptional returns true for the commands that still run when the
+// config file cannot be read so that a broken file can be fixed.
+func configOptional(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "config", "version", "completion", "help":
+			return true
+		}
+	}
+	return false
+}
+
+func cmdConfig() (*config, error) {
+	file, err := configFile()
+	if err != nil {
+		return nil, err
+	}
+	return loadConfig(file)
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/harrybrwn/yt/pkg/logging"
+	"github.com/harrybrwn/yt/pkg/terminal"
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/pflag"
+)
+
+// downloadOptions holds the options for how videos are downloaded and
+// where they are saved.
+type downloadOptions struct {
+	output      string
+	format      string
+	concurrency int
+	retries     int
+	progress    string
+
+	events *eventLog
+	log    *logging.Logger
+	once   sync.Once
+	sem    chan struct{}
+}
+
+const formatUsage = `Stream to download: "best", "worst", a quality such as "720p" or an itag`
+
+func (do *downloadOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&do.output, "output", "o", "{{.FileName}}", "Template for the file name of each download (without extension)")
+	flags.StringVarP(&do.format, "format", "f", "best", formatUsage)
+	flags.IntVar(&do.concurrency, "concurrency", 4, "Number of videos to download at once (0 for no limit)")
+	flags.IntVar(&do.retries, "retries", 2, "Number of times to retry a failed download")
+	flags.StringVar(&do.progress, "progress", "text", "How to show progress: \"text\" or \"json\" for a line of json for each event")
+}
+
+// setup checks the progress option and returns the terminal that the
+// command prints to. With json progress the events are written to w and
+// the normal text output is dropped.
+func (do *downloadOptions) setup(opts *options, w io.Writer) (*terminal.Terminal, error) {
+	do.log = opts.log
+	switch do.progress {
+	case "", "text":
+		return opts.progressTerminal(), nil
+	case "json":
+		do.events = newEventLog(w)
+		return terminal.Plain(ioutil.Discard), nil
+	}
+	return nil, fmt.Errorf("unknown progress format %q", do.progress)
+}
+
+// fileName returns the name of the file that a video is downloaded to
+// using the output template. Any directories in the template are created.
+func (do *downloadOptions) fileName(v *youtube.Video, dir, ext string) (string, error) {
+	tmpl, err := template.New("output").Parse(do.output)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, v); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return "", fmt.Errorf("output template %q gave an empty file name", do.output)
+	}
+	name = filepath.Join(dir, name) + ext
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// acquire waits until there are less than the max number of downloads
+// running. The returned function must be called when the download is done.
+func (do *downloadOptions) acquire() (release func()) {
+	do.once.Do(func() {
+		if do.concurrency > 0 {
+			do.sem = make(chan struct{}, do.concurrency)
+		}
+	})
+	if do.sem == nil {
+		return func() {}
+	}
+	do.sem <- struct{}{}
+	return func() { <-do.sem }
+}
+
+// retry calls fn until it succeeds or it has been retried the max number
+// of times.
+func (do *downloadOptions) retry(fn func() error) (err error) {
+	for i := 0; ; i++ {
+		if err = fn(); err == nil || i >= do.retries || errors.Is(err, errCanceled) {
+			return err
+		}
+		time.Sleep(time.Duration(i+1) * time.Second)
+	}
+}
+
+// download downloads the stream chosen by the format option to a file
+// and returns the stream. The stream is nil for live videos.
+func (do *downloadOptions) download(v *youtube.Video, name string, audio bool, status *videoStatus) (*youtube.Stream, error) {
+	if v.IsLive && !audio {
+		return nil, v.Download(name)
+	}
+	s, err := selectStream(v, do.format, audio)
+	if err != nil {
+		return nil, err
+	}
+	quality := s.QualityLabel
+	if quality == "" {
+		quality = fmt.Sprintf("%dkbps", s.Bitrate/1000)
+	}
+	do.log.Verbosef("%s: using stream %d (%s, %s)", v.ID, s.ITag, s.MimeType.ContentType, quality)
+	total, _ := strconv.ParseInt(s.ContentLength, 10, 64)
+	// the file only gets its name once it is complete so that a failed
+	// download is not mistaken for a finished one
+	part := name + ".part"
+	err = do.retry(func() error {
+		file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = s.WriteTo(status.writer(file, total))
+		if e := file.Close(); err == nil {
+			err = e
+		}
+		return err
+	})
+	if err != nil {
+		os.Remove(part)
+		return s, err
+	}
+	return s, os.Rename(part, name)
+}
+
+// selectStream finds the stream described by a format selector. The
+// streams from the video's DASH manifest are only added when none of the
+// video's streams match the format or the match cannot be downloaded.
+func selectStream(v *youtube.Video, format string, audio bool) (*youtube.Stream, error) {
+	s, err := findStream(v, format, audio)
+	if err == nil && s != nil && (s.URL != "" || s.Segmented()) {
+		return s, nil
+	}
+	if v.AddDASHStreams() != nil {
+		return s, err
+	}
+	if s, err = findStream(v, format, audio); err == nil && s == nil {
+		err = errors.New("no streams")
+	}
+	return s, err
+}
+
+func findStream(v *youtube.Video, format string, audio bool) (*youtube.Stream, error) {
+	streams := []youtube.Stream(v.Streams)
+	if audio {
+		streams = []youtube.Stream(v.AudioStreams)
+	}
+	switch format {
+	case "", "best":
+		if !audio {
+			return v.BestStream(), nil
+		}
+		if s := v.BestAudioStream(); s != nil {
+			return s, nil
+		}
+		return nil, errors.New("no audio streams")
+	case "worst":
+		var worst *youtube.Stream
+		for i, s := range streams {
+			if worst == nil || s.Height+s.Width < worst.Height+worst.Width ||
+				(s.Height+s.Width == worst.Height+worst.Width && s.Bitrate < worst.Bitrate) {
+				worst = &streams[i]
+			}
+		}
+		if worst != nil {
+			return worst, nil
+		}
+	default:
+		if itag, err := strconv.Atoi(format); err == nil {
+			for _, list := range [][]youtube.Stream{v.Streams, v.VideoStreams, v.AudioStreams} {
+				for i := range list {
+					if list[i].ITag == itag {
+						return &list[i], nil
+					}
+				}
+			}
+			break
+		}
+		for i, s := range streams {
+			if s.QualityLabel == format || strings.HasPrefix(s.QualityLabel, format) {
+				return &streams[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no stream matches the format %q", format)
+}
+
+// parseRate parses a download speed in bytes per second such as "500K",
+// "2M" or "1.5MiB". The K, M and G suffixes are multiples of 1024. An
+// empty rate is zero.
+func parseRate(rate string) (int64, error) {
+	n, err := parseSize(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(rate)), "/S"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", rate)
+	}
+	return n, nil
+}
+
+// parseSize parses a number of bytes such as "500K" or "1.5GiB" the same
+// way as parseRate.
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(s, "B")
+	s = strings.TrimSuffix(s, "I")
+	mult := 1.0
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(n * mult), nil
+}
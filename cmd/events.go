@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harrybrwn/yt/pkg/ffmpeg"
+	"github.com/harrybrwn/yt/youtube"
+)
+
+// eventLog writes a line of json for every change in a video's download
+// when --progress=json is used. A nil eventLog writes nothing.
+type eventLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	fn  func(event)
+}
+
+func newEventLog(w io.Writer) *eventLog {
+	return &eventLog{enc: json.NewEncoder(w)}
+}
+
+// newEventFunc creates an eventLog that calls fn with every event.
+func newEventFunc(fn func(event)) *eventLog {
+	return &eventLog{fn: fn}
+}
+
+// Event names
+const (
+	eventQueued    = "queued"
+	eventFetched   = "metadata-fetched"
+	eventStarted   = "started"
+	eventProgress  = "progress"
+	eventCompleted = "completed"
+	eventFailed    = "failed"
+	eventSkipped   = "skipped"
+)
+
+type event struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	Playlist  string    `json:"playlist,omitempty"`
+	Index     int       `json:"playlist_index,omitempty"`
+	File      string    `json:"file,omitempty"`
+	Bytes     int64     `json:"bytes,omitempty"`
+	Total     int64     `json:"total,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ErrorType string    `json:"error_type,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func (l *eventLog) emit(e event) {
+	if l == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.fn != nil {
+		l.fn(e)
+		return
+	}
+	l.enc.Encode(&e)
+}
+
+// errorType sorts errors into a few kinds that scripts can act on.
+func errorType(err error) string {
+	var (
+		integrity *youtube.IntegrityError
+		netErr    net.Error
+		pathErr   *os.PathError
+	)
+	switch {
+	case youtube.IsUnplayable(err):
+		return "unavailable"
+	case errors.As(err, &integrity):
+		return "integrity"
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.Is(err, ffmpeg.ErrNotFound), strings.HasPrefix(err.Error(), "ffmpeg"):
+		return "ffmpeg"
+	case errors.As(err, &pathErr):
+		return "filesystem"
+	}
+	return "other"
+}
@@ -0,0 +1,518 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/pflag"
+)
+
+const matchFilterUsage = `Only download videos that match an expression such as
+"duration < 1h & !was_live & title !~ '(?i)#shorts'"`
+
+// filterOptions holds the options that decide which videos are skipped
+// before any of their streams are downloaded.
+type filterOptions struct {
+	match       string
+	minDuration string
+	maxDuration string
+	minFilesize string
+	maxFilesize string
+	dateAfter   string
+	dateBefore  string
+	matchTitle  string
+	rejectTitle string
+
+	conditions []condition
+}
+
+// condition is a single part of a filter with a description of why a
+// video that does not match is skipped.
+type condition struct {
+	filter filter
+	reason string
+}
+
+func (fo *filterOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&fo.match, "match-filter", "", matchFilterUsage)
+	flags.StringVar(&fo.minDuration, "min-duration", "", "Skip videos shorter than this (\"90\", \"1:30\" or \"1m30s\")")
+	flags.StringVar(&fo.maxDuration, "max-duration", "", "Skip videos longer than this")
+	flags.StringVar(&fo.minFilesize, "min-filesize", "", "Skip downloads smaller than this (\"50K\", \"10M\")")
+	flags.StringVar(&fo.maxFilesize, "max-filesize", "", "Skip downloads larger than this")
+	flags.StringVar(&fo.dateAfter, "date-after", "", "Only download videos published on or after this date (YYYYMMDD)")
+	flags.StringVar(&fo.dateBefore, "date-before", "", "Only download videos published on or before this date (YYYYMMDD)")
+	flags.StringVar(&fo.matchTitle, "match-title", "", "Only download videos with titles matching this regular expression")
+	flags.StringVar(&fo.rejectTitle, "reject-title", "", "Skip videos with titles matching this regular expression")
+}
+
+// compile parses the filter expression and the options into conditions.
+func (fo *filterOptions) compile() error {
+	fo.conditions = nil
+	if fo.match != "" {
+		f, err := parseFilter(fo.match)
+		if err != nil {
+			return err
+		}
+		fo.conditions = append(fo.conditions, condition{f, fmt.Sprintf("does not match %q", fo.match)})
+	}
+	for _, opt := range []struct {
+		value, field, op, reason string
+	}{
+		{fo.minDuration, "duration", ">=", "shorter than %s"},
+		{fo.maxDuration, "duration", "<=", "longer than %s"},
+		{fo.minFilesize, "filesize", ">=?", "smaller than %s"},
+		{fo.maxFilesize, "filesize", "<=?", "larger than %s"},
+		{fo.dateAfter, "upload_date", ">=", "published before %s"},
+		{fo.dateBefore, "upload_date", "<=", "published after %s"},
+		{fo.matchTitle, "title", "~=", "title does not match %q"},
+		{fo.rejectTitle, "title", "!~", "title matches %q"},
+	} {
+		if opt.value == "" {
+			continue
+		}
+		c, err := newComparison(opt.field, opt.op, opt.value)
+		if err != nil {
+			return err
+		}
+		fo.conditions = append(fo.conditions, condition{c, fmt.Sprintf(opt.reason, opt.value)})
+	}
+	return nil
+}
+
+// check returns a *skipError if the video should not be downloaded. The
+// file size is the size of the stream chosen by the format.
+func (fo *filterOptions) check(v *youtube.Video, format string, audio bool) error {
+	if fo == nil || len(fo.conditions) == 0 {
+		return nil
+	}
+	var size int64
+	if s, err := selectStream(v, format, audio); err == nil && s != nil {
+		size, _ = strconv.ParseInt(s.ContentLength, 10, 64)
+	}
+	fields := videoFields(v, size)
+	for _, c := range fo.conditions {
+		if !c.filter.match(fields) {
+			return &skipError{reason: c.reason}
+		}
+	}
+	return nil
+}
+
+// skipError is returned by a video handler when a video is skipped on
+// purpose.
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string { return "skipped: " + e.reason }
+
+// fieldKind is the type of a metadata field which decides how the values
+// it is compared to are parsed.
+type fieldKind int
+
+const (
+	numberField fieldKind = iota
+	durationField
+	sizeField
+	stringField
+	dateField
+	boolField
+	listField
+)
+
+var filterFields = map[string]fieldKind{
+	"id":             stringField,
+	"title":          stringField,
+	"author":         stringField,
+	"channel_id":     stringField,
+	"category":       stringField,
+	"duration":       durationField,
+	"views":          numberField,
+	"filesize":       sizeField,
+	"upload_date":    dateField,
+	"is_live":        boolField,
+	"was_live":       boolField,
+	"is_upcoming":    boolField,
+	"keywords":       listField,
+	"playlist_index": numberField,
+}
+
+// videoFields returns the metadata that filters are evaluated against.
+// Fields that are not known are left out.
+func videoFields(v *youtube.Video, size int64) map[string]interface{} {
+	fields := map[string]interface{}{
+		"id":          v.ID,
+		"title":       v.Title,
+		"author":      v.Author,
+		"channel_id":  v.ChannelID,
+		"category":    v.Category,
+		"duration":    v.Length().Seconds(),
+		"is_live":     v.IsLive,
+		"was_live":    v.IsLiveContent && !v.IsLive,
+		"is_upcoming": v.IsUpcoming,
+		"keywords":    v.Keywords,
+	}
+	if views, err := strconv.ParseFloat(v.ViewCount, 64); err == nil {
+		fields["views"] = views
+	}
+	if size > 0 {
+		fields["filesize"] = float64(size)
+	}
+	if v.PublishDate != "" {
+		fields["upload_date"] = v.PublishDate
+	}
+	if v.PlaylistIndex > 0 {
+		fields["playlist_index"] = float64(v.PlaylistIndex)
+	}
+	return fields
+}
+
+// filter is a parsed filter expression.
+type filter interface {
+	match(fields map[string]interface{}) bool
+}
+
+type andFilter []filter
+
+func (f andFilter) match(fields map[string]interface{}) bool {
+	for _, sub := range f {
+		if !sub.match(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+type orFilter []filter
+
+func (f orFilter) match(fields map[string]interface{}) bool {
+	for _, sub := range f {
+		if sub.match(fields) {
+			return true
+		}
+	}
+	return false
+}
+
+type notFilter struct{ f filter }
+
+func (f notFilter) match(fields map[string]interface{}) bool { return !f.f.match(fields) }
+
+// truthy matches fields that are true, non-zero or not empty.
+type truthy string
+
+func (f truthy) match(fields map[string]interface{}) bool {
+	switch v := fields[string(f)].(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []string:
+		return len(v) > 0
+	}
+	return false
+}
+
+// comparison compares a field to a value. When optional is true a video
+// that does not have the field matches.
+type comparison struct {
+	field    string
+	op       string
+	optional bool
+	num      float64
+	str      string
+	re       *regexp.Regexp
+}
+
+func newComparison(field, op, value string) (*comparison, error) {
+	kind, ok := filterFields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter field %q", field)
+	}
+	c := &comparison{field: field, op: strings.TrimSuffix(op, "?"), optional: strings.HasSuffix(op, "?")}
+	if c.op == "~=" || c.op == "!~" {
+		if kind != stringField && kind != dateField && kind != listField {
+			return nil, fmt.Errorf("cannot use %s with %s", c.op, field)
+		}
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		c.re = re
+		return c, nil
+	}
+	var err error
+	switch kind {
+	case numberField:
+		c.num, err = strconv.ParseFloat(value, 64)
+	case durationField:
+		var d time.Duration
+		d, err = parseClipTime(value)
+		c.num = d.Seconds()
+	case sizeField:
+		var n int64
+		n, err = parseSize(value)
+		c.num = float64(n)
+	case dateField:
+		c.str, err = parseFilterDate(value)
+	case boolField:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		c.str = strconv.FormatBool(b)
+	default:
+		c.str = value
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q for %s", value, field)
+	}
+	switch {
+	case (kind == boolField || kind == listField) && c.op != "==" && c.op != "!=":
+		return nil, fmt.Errorf("cannot use %s with %s", c.op, field)
+	case !validOps[c.op]:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+	return c, nil
+}
+
+var validOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func (c *comparison) match(fields map[string]interface{}) bool {
+	value, ok := fields[c.field]
+	if !ok {
+		return c.optional
+	}
+	switch v := value.(type) {
+	case float64:
+		return compare(c.op, v-c.num)
+	case bool:
+		return compare(c.op, float64(strings.Compare(strconv.FormatBool(v), c.str)))
+	case string:
+		if c.re != nil {
+			return c.re.MatchString(v) == (c.op == "~=")
+		}
+		return compare(c.op, float64(strings.Compare(v, c.str)))
+	case []string:
+		var found bool
+		for _, s := range v {
+			if (c.re != nil && c.re.MatchString(s)) || (c.re == nil && strings.EqualFold(s, c.str)) {
+				found = true
+				break
+			}
+		}
+		return found == (c.op == "~=" || c.op == "==")
+	}
+	return false
+}
+
+// compare applies an operator to the difference between two values.
+func compare(op string, diff float64) bool {
+	switch op {
+	case "==":
+		return diff == 0
+	case "!=":
+		return diff != 0
+	case "<":
+		return diff < 0
+	case "<=":
+		return diff <= 0
+	case ">":
+		return diff > 0
+	case ">=":
+		return diff >= 0
+	}
+	return false
+}
+
+// parseFilterDate parses a date given as YYYYMMDD or YYYY-MM-DD into the
+// format of a video's publish date.
+func parseFilterDate(s string) (string, error) {
+	for _, layout := range []string{"20060102", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", s)
+}
+
+// parseFilter parses a filter expression. Comparisons are written as
+// "field op value" and can be combined with "&", "|", "!" and parentheses.
+func parseFilter(s string) (filter, error) {
+	p := &filterParser{s: s}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return f, nil
+}
+
+type filterParser struct {
+	s   string
+	pos int
+}
+
+func (p *filterParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("match filter: %s (at column %d)", fmt.Sprintf(format, a...), p.pos+1)
+}
+
+func (p *filterParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// accept skips over any of the tokens if one is next.
+func (p *filterParser) accept(tokens ...string) bool {
+	p.skipSpace()
+	for _, tok := range tokens {
+		if strings.HasPrefix(p.s[p.pos:], tok) {
+			p.pos += len(tok)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *filterParser) or() (filter, error) {
+	var fs orFilter
+	for {
+		f, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+		if !p.accept("||", "|") {
+			break
+		}
+	}
+	if len(fs) == 1 {
+		return fs[0], nil
+	}
+	return fs, nil
+}
+
+func (p *filterParser) and() (filter, error) {
+	var fs andFilter
+	for {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+		if !p.accept("&&", "&") {
+			break
+		}
+	}
+	if len(fs) == 1 {
+		return fs[0], nil
+	}
+	return fs, nil
+}
+
+func (p *filterParser) unary() (filter, error) {
+	if p.accept("!") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{f}, nil
+	}
+	if p.accept("(") {
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing ')'")
+		}
+		return f, nil
+	}
+	field := p.ident()
+	if field == "" {
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("unexpected end of filter")
+		}
+		return nil, p.errorf("expected a field name")
+	}
+	if _, ok := filterFields[field]; !ok {
+		return nil, p.errorf("unknown field %q", field)
+	}
+	op := p.operator()
+	if op == "" {
+		return truthy(field), nil
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newComparison(field, op, value)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return c, nil
+}
+
+func (p *filterParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] == '_' || ('a' <= p.s[p.pos] && p.s[p.pos] <= 'z')) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *filterParser) operator() string {
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "~=", "!~", "<", ">"} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			p.pos += len(op)
+			if p.pos < len(p.s) && p.s[p.pos] == '?' {
+				p.pos++
+				op += "?"
+			}
+			return op
+		}
+	}
+	return ""
+}
+
+// value reads a quoted string or a word that ends at a space, an
+// operator or a closing parenthesis.
+func (p *filterParser) value() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return "", p.errorf("missing value")
+	}
+	if q := p.s[p.pos]; q == '\'' || q == '"' {
+		var b strings.Builder
+		for p.pos++; p.pos < len(p.s); p.pos++ {
+			c := p.s[p.pos]
+			switch {
+			case c == q:
+				p.pos++
+				return b.String(), nil
+			case c == '\\' && p.pos+1 < len(p.s) && p.s[p.pos+1] == q:
+				p.pos++
+				c = q
+			}
+			b.WriteByte(c)
+		}
+		return "", p.errorf("unterminated string")
+	}
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t&|()", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", p.errorf("missing value")
+	}
+	return p.s[start:p.pos], nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/harrybrwn/yt/pkg/terminal"
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/cobra"
+)
+
+func newInfoCmd(opts *options) *cobra.Command {
+	type infocommand struct {
+		json, writeJSON bool
+		template        string
+	}
+	ic := infocommand{}
+
+	infoCmd := &cobra.Command{
+		Use:   "info <id|url>...",
+		Short: "Show information about youtube videos",
+		Long: `Show information about youtube videos.
+
+The --template flag takes a Go template that is executed with each video,
+for example '{{.Title}} {{.ViewCount}}'.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("no Arguments\n\nUse \"yt info --help\" for more information")
+			}
+			var tmpl *template.Template
+			if ic.template != "" {
+				var err error
+				tmpl, err = template.New("info").Funcs(infoFuncs).Parse(ic.template)
+				if err != nil {
+					return err
+				}
+			}
+			out := cmd.OutOrStdout()
+			for i, arg := range args {
+				id, err := videoID(arg)
+				if err != nil {
+					return err
+				}
+				v, err := lookupInfo(opts.youtube(), id)
+				if err != nil {
+					return err
+				}
+				switch {
+				case ic.writeJSON:
+					err = v.SaveInfo(filepath.Join(opts.path, v.FileName)+infoExt, nil)
+				case ic.json:
+					err = v.WriteInfo(out, nil)
+				case tmpl != nil:
+					err = executeTemplate(out, tmpl, v)
+				default:
+					if i > 0 {
+						fmt.Fprintln(out)
+					}
+					err = printInfo(opts.terminal(), v)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	flags := infoCmd.Flags()
+	flags.BoolVar(&ic.json, "json", ic.json, "Print the video's metadata and streams as json")
+	flags.BoolVar(&ic.writeJSON, "write-info-json", ic.writeJSON, "Save the video's metadata to a .info.json file")
+	flags.StringVarP(&ic.template, "template", "t", ic.template, "Print the video info using a Go template")
+	return infoCmd
+}
+
+// lookupInfo gets a video's metadata even if the video is not playable.
+func lookupInfo(c *youtube.Client, id string) (*youtube.Video, error) {
+	if isInfoFile(id) {
+		return c.LoadInfo(id)
+	}
+	return c.LookupVideo(id)
+}
+
+var infoFuncs = template.FuncMap{
+	"duration": formatDuration,
+	"join":     strings.Join,
+}
+
+func executeTemplate(w io.Writer, tmpl *template.Template, v *youtube.Video) error {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, v); err != nil {
+		return err
+	}
+	s := b.String()
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func printInfo(w *terminal.Terminal, v *youtube.Video) error {
+	playable := w.Green("yes")
+	if err := v.PlayabilityError(); err != nil {
+		playable = w.Red("no") + fmt.Sprintf(" (%v)", err)
+	}
+	qualities := strings.Join(v.Qualities(), ", ")
+	if s := v.BestAudioStream(); s != nil {
+		if qualities != "" {
+			qualities += ", "
+		}
+		qualities += fmt.Sprintf("audio %dkbps", s.Bitrate/1000)
+	}
+	var live string
+	switch {
+	case v.IsLive:
+		live = "live now"
+	case v.IsUpcoming && !v.ScheduledStart.IsZero():
+		live = "starts " + v.ScheduledStart.Local().Format(time.RFC1123)
+	case v.IsUpcoming:
+		live = "upcoming"
+	}
+	fields := []struct{ name, value string }{
+		{"Title", v.Title},
+		{"Channel", fmt.Sprintf("%s (%s)", v.Author, v.ChannelID)},
+		{"Duration", formatDuration(v.Length())},
+		{"Views", formatCount(v.ViewCount)},
+		{"Published", v.PublishDate},
+		{"Qualities", qualities},
+		{"Live", live},
+		{"Playable", playable},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%-10s %s\n", f.name+":", f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatDuration formats a duration as h:mm:ss or m:ss.
+func formatDuration(d time.Duration) string {
+	s := int64(d / time.Second)
+	h, m := s/3600, (s/60)%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", m, s%60)
+}
+
+// formatCount adds thousands separators to a number.
+func formatCount(n string) string {
+	if _, err := strconv.ParseUint(n, 10, 64); err != nil {
+		return n
+	}
+	var b strings.Builder
+	for i, c := range n {
+		if i > 0 && (len(n)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// itemOptions selects which entries of a playlist are downloaded and in
+// what order.
+type itemOptions struct {
+	items   string
+	start   int
+	end     int
+	reverse bool
+	random  bool
+}
+
+func (o *itemOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.items, "items", "", "Playlist entries to download in the order given such as \"1-10,15,20-\" (counting from 1)")
+	flags.IntVar(&o.start, "playlist-start", 1, "First playlist entry to download")
+	flags.IntVar(&o.end, "playlist-end", 0, "Last playlist entry to download (0 for the last entry)")
+	flags.BoolVar(&o.reverse, "playlist-reverse", false, "Download playlist entries in reverse order")
+	flags.BoolVar(&o.random, "playlist-random", false, "Download playlist entries in a random order")
+}
+
+func (o *itemOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.start < 1 {
+		return errors.New("--playlist-start must be at least 1")
+	}
+	if o.end != 0 && o.end < o.start {
+		return errors.New("--playlist-end is before --playlist-start")
+	}
+	if o.random && o.reverse {
+		return errors.New("--playlist-random cannot be used with --playlist-reverse")
+	}
+	_, err := parseItems(o.items)
+	return err
+}
+
+// selectItems returns the indexes, counting from 1, of the entries of a
+// playlist with n entries to download in the order they are downloaded.
+// Entries are in the order of the --items list and are only included
+// once.
+func (o *itemOptions) selectItems(n int) ([]int, error) {
+	if o == nil {
+		o = &itemOptions{start: 1}
+	}
+	ranges, err := parseItems(o.items)
+	if err != nil {
+		return nil, err
+	}
+	if ranges == nil {
+		ranges = itemRanges{{1, 0}}
+	}
+	var (
+		indexes []int
+		seen    = make(map[int]bool)
+	)
+	for _, rng := range ranges {
+		last := rng[1]
+		if last == 0 || last > n {
+			last = n
+		}
+		for i := rng[0]; i <= last; i++ {
+			if seen[i] || i < o.start || (o.end > 0 && i > o.end) {
+				continue
+			}
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	switch {
+	case o.random:
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(indexes), func(i, j int) {
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+		})
+	case o.reverse:
+		for i, j := 0, len(indexes)-1; i < j; i, j = i+1, j-1 {
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+		}
+	}
+	return indexes, nil
+}
+
+// itemRanges is a list of inclusive ranges. An end of zero has no end.
+type itemRanges [][2]int
+
+// parseItems parses a comma separated list of entries and ranges such as
+// "1-10,15,20-". An empty list is nil.
+func parseItems(s string) (itemRanges, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var ranges itemRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		var (
+			rng [2]int
+			err error
+		)
+		bounds := strings.SplitN(part, "-", 2)
+		if rng[0], err = strconv.Atoi(bounds[0]); err != nil || rng[0] < 1 {
+			return nil, fmt.Errorf("invalid playlist item %q", part)
+		}
+		switch {
+		case len(bounds) == 1:
+			rng[1] = rng[0]
+		case bounds[1] != "":
+			if rng[1], err = strconv.Atoi(bounds[1]); err != nil || rng[1] < rng[0] {
+				return nil, fmt.Errorf("invalid playlist range %q", part)
+			}
+		}
+		ranges = append(ranges, rng)
+	}
+	return ranges, nil
+}
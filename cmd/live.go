@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/pflag"
+)
+
+// liveOptions holds the options for recording live streams.
+type liveOptions struct {
+	duration     time.Duration
+	fromStart    bool
+	wait         bool
+	waitInterval time.Duration
+}
+
+func (lo *liveOptions) addFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&lo.duration, "duration", 0, "Stop recording live streams after this long")
+	flags.BoolVar(&lo.fromStart, "live-from-start", false, "Record live streams from the oldest part still available when DVR is enabled")
+	flags.BoolVar(&lo.wait, "wait-for-live", false, "Wait for upcoming live streams and premieres to start")
+	flags.DurationVar(&lo.waitInterval, "wait-interval", time.Minute, "How often to check if an upcoming stream has started")
+}
+
+// lookup returns the function used to get videos.
+func (lo *liveOptions) lookup(opts *options) videoLookup {
+	if !lo.wait {
+		return opts.lookupVideo
+	}
+	return func(id string) (*youtube.Video, error) {
+		return opts.youtube().WaitForLive(id, lo.waitInterval)
+	}
+}
+
+func (lo *liveOptions) options() *youtube.LiveOptions {
+	return &youtube.LiveOptions{
+		Duration:  lo.duration,
+		FromStart: lo.fromStart,
+	}
+}
@@ -18,95 +18,251 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/harrybrwn/yt/pkg/terminal"
 	"github.com/harrybrwn/yt/youtube"
 	"github.com/spf13/cobra"
 )
 
-var pExt string
-
-var playlistCmd = &cobra.Command{
-	Use:     "playlist [ids...]",
-	Short:   "A tool for downloading youtube playlists.",
-	Aliases: []string{"p", "plst"},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		var (
-			err error
-			wg  sync.WaitGroup
-		)
-		audio, err := cmd.Flags().GetBool("audio")
-		if err != nil {
-			return err
-		}
-
-		setCursorOnHandler()
-		terminal.CursorOff()
-		defer terminal.CursorOn()
-		go func() {
-			for i := 0; ; i++ {
-				fmt.Printf("\r%s... %c", terminal.Red("Downloading"), getLoadingChar(i))
-				time.Sleep(loadingInterval)
+func newPlaylistCmd(opts *options) *cobra.Command {
+	var (
+		ext     string
+		audio   bool
+		dl      = &downloadOptions{}
+		items   = &itemOptions{}
+		filters = &filterOptions{}
+		lists   = &playlistFileOptions{}
+	)
+	c := &cobra.Command{
+		Use:     "playlist [ids...]",
+		Long:    `Download youtube playlists or all the uploads of a channel.`,
+		Short:   "A tool for downloading youtube playlists.",
+		Aliases: []string{"p", "plst"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			b := &batch{}
+			if file, err := cmd.Flags().GetString("batch-file"); err != nil {
+				return err
+			} else if file != "" {
+				if b, err = readBatchFile(file, cmd.InOrStdin()); err != nil {
+					return err
+				}
 			}
-		}()
-
-		wg.Add(len(args))
-		for _, id := range args {
-			err = downloadPlaylist(id, audio, &wg)
+			for _, arg := range args {
+				if err := b.addList(arg); err != nil {
+					return err
+				}
+			}
+			if err := items.validate(); err != nil {
+				return err
+			}
+			if err := filters.compile(); err != nil {
+				return err
+			}
+			if err := lists.validate(); err != nil {
+				return err
+			}
+			dir, err := opts.dir()
+			if err != nil {
+				return err
+			}
+			term, err := dl.setup(opts, cmd.OutOrStdout())
 			if err != nil {
-				fmt.Printf("\r%s: %v\n", terminal.Red("Error"), err)
+				return err
 			}
+			board := newStatusBoard(term, dl.events, opts.log)
+			defer board.stop()
+			t := &target{dir: dir, ext: ext, audio: audio, downloadOptions: dl, client: opts.youtube(), term: term, board: board, items: items, filter: filters, playlistFiles: lists}
+			if audio {
+				t.ext = ".mpa"
+			}
+			if len(b.videos) > 0 {
+				asyncDownload(t.board, b.videos, opts.lookupVideo, t.downloadOne)
+			}
+			downloadPlaylists(b.playlists, t)
+			return board.finish()
+		},
+	}
+	flags := c.Flags()
+	flags.BoolVarP(&audio, "audio", "a", false, "download the audio from all the videos in the specifies playlist")
+	flags.StringVarP(&ext, "extension", "e", ".mp4", "file extension used for video download")
+	flags.String("batch-file", "", "Read urls or ids from a file, one per line ('-' for stdin)")
+	dl.addFlags(flags)
+	items.addFlags(flags)
+	filters.addFlags(flags)
+	lists.addFlags(flags)
+	return c
+}
+
+// target is where and how the videos of a command are downloaded.
+type target struct {
+	dir   string
+	ext   string
+	audio bool
+	*downloadOptions
+	client *youtube.Client
+	term   *terminal.Terminal
+	board  *statusBoard
+	items  *itemOptions
+	filter *filterOptions
+	pp     *postprocessor
+
+	playlistFiles *playlistFileOptions
+}
+
+// downloadPlaylists downloads a list of playlists or channels
+// and waits for them to finish. Each playlist is saved in its
+// own directory inside the target directory.
+func downloadPlaylists(lists []*youtube.URL, t *target) {
+	var wg sync.WaitGroup
+	wg.Add(len(lists))
+	for _, u := range lists {
+		err := downloadPlaylist(u, t, &wg)
+		if err != nil {
+			t.board.add(listID(u)).failed(err)
+			t.log.Errorf("%v", err)
 		}
-		wg.Wait()
-		return nil
-	},
+	}
+	wg.Wait()
 }
 
-func downloadPlaylist(id string, getAudio bool, wg *sync.WaitGroup) error {
+// playlistDir returns the directory in root that the videos of a playlist
+// are saved to. The title is chosen by the playlist's uploader so it is
+// made safe like the file names of videos, falling back to the playlist's
+// id, and a directory outside of root is an error.
+func playlistDir(root, title, id string) (string, error) {
+	name := strings.TrimSpace(youtube.SafeFileName(title))
+	if name == "" {
+		name = strings.TrimSpace(youtube.SafeFileName(id))
+	}
+	dir := filepath.Join(root, name)
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || name == "" || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("cannot save playlist %q in %s", title, root)
+	}
+	return dir, nil
+}
+
+func downloadPlaylist(u *youtube.URL, t *target, wg *sync.WaitGroup) error {
 	defer wg.Done()
-	plst, err := youtube.NewPlaylist(id)
+	plst, err := lookupPlaylist(t.client, u)
+	if err != nil {
+		return err
+	}
+	dir, err := playlistDir(t.dir, plst.Title, listID(u))
 	if err != nil {
 		return err
 	}
-	path = filepath.Join(path, plst.Title)
-	if _, err = os.Stat(path); os.IsNotExist(err) {
-		if err = os.Mkdir(path, 0755); err != nil {
+	if _, err = os.Stat(dir); os.IsNotExist(err) {
+		if err = os.Mkdir(dir, 0755); err != nil {
 			return err
 		}
 	}
 
-	wg.Add(len(plst.Videos))
-	for _, video := range plst.Videos {
-		go func(id string) {
+	indexes, err := t.items.selectItems(len(plst.Videos))
+	if err != nil {
+		return err
+	}
+	var (
+		mu      sync.Mutex
+		items   sync.WaitGroup
+		entries []*syncEntry
+	)
+	downloadItems(plst, indexes, dir, t, &items, func(v *youtube.Video, name string) {
+		file, err := filepath.Rel(dir, name)
+		if err != nil {
+			file = name
+		}
+		e := &syncEntry{File: file, Title: v.Title, Index: v.PlaylistIndex, Duration: plst.Videos[v.PlaylistIndex-1].LengthSeconds}
+		mu.Lock()
+		entries = append(entries, e)
+		mu.Unlock()
+	})
+	// write the playlist file once every video is done
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		items.Wait()
+		if len(entries) == 0 {
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Index < entries[j].Index })
+		if err := t.playlistFiles.write(dir, plst.Title, entries); err != nil {
+			t.log.Errorf("%v", err)
+		}
+	}()
+	return nil
+}
+
+// downloadItems starts downloading the videos at the 1-based indexes of a
+// playlist into dir. The wait group is done when each video is finished
+// and saved is called with each video that was downloaded.
+func downloadItems(plst *youtube.Playlist, indexes []int, dir string, t *target, wg *sync.WaitGroup, saved func(v *youtube.Video, name string)) {
+	queue := make([]*videoStatus, len(indexes))
+	for i, index := range indexes {
+		queue[i] = t.board.add(plst.Videos[index-1].ID)
+	}
+	wg.Add(len(indexes))
+	for i, index := range indexes {
+		// wait for a free download before starting each video so
+		// that they start in the order they were selected
+		release := t.acquire()
+		go func(id string, index int, status *videoStatus) {
 			defer wg.Done()
+			defer release()
+			defer status.done()
 			var name string
-			v, err := youtube.NewVideo(id)
+			v, err := t.client.NewVideo(id)
 			if err != nil {
 				goto Error
 			}
-			name = filepath.Join(path, v.FileName)
-			if getAudio {
-				name += ".mpa"
-				err = v.DownloadAudio(name)
-			} else {
-				name += pExt
-				err = v.Download(name)
+			v.PlaylistIndex, v.PlaylistTitle = index, plst.Title
+			status.fetched(v)
+			if err = t.filter.check(v, t.format, t.audio); err != nil {
+				goto Error
 			}
-			if err != nil {
+			if name, err = t.fileName(v, dir, t.ext); err != nil {
 				goto Error
 			}
-			fmt.Printf("\r%s %s\n", terminal.Green("Downloaded"), name)
+			if err = downloadVideo(v, name, t, status); err != nil {
+				goto Error
+			}
+			status.completed()
+			if saved != nil {
+				saved(v, name)
+			}
 			return
 		Error:
-			fmt.Printf("\r%s: %v\n", terminal.Red("Error"), err)
-		}(video.ID)
+			if status.finish(err) {
+				t.log.Errorf("%s: %v", id, err)
+			}
+		}(plst.Videos[index-1].ID, index, queue[i])
 	}
-	return nil
 }
 
-func init() {
-	playlistCmd.Flags().BoolP("audio", "a", false, "download the audio from all the videos in the specifies playlist")
-	playlistCmd.Flags().StringVarP(&pExt, "extension", "e", ".mp4", "file extension used for video download")
+// downloadOne downloads a video that is not part of a playlist into the
+// target directory.
+func (t *target) downloadOne(v *youtube.Video, status *videoStatus) error {
+	if err := t.filter.check(v, t.format, t.audio); err != nil {
+		return err
+	}
+	release := t.acquire()
+	defer release()
+	name, err := t.fileName(v, t.dir, t.ext)
+	if err != nil {
+		return err
+	}
+	return downloadVideo(v, name, t, status)
+}
+
+func downloadVideo(v *youtube.Video, name string, t *target, status *videoStatus) error {
+	status.start(name)
+	chosen, err := t.download(v, name, t.audio, status)
+	if err != nil {
+		return err
+	}
+	t.term.Println("%s %s", t.term.Green("Downloaded"), name)
+	return t.pp.run(v, name, t.audio, chosen)
 }
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/pflag"
+)
+
+// playlistFileOptions holds the formats of the playlist files that are
+// written next to the videos of a playlist.
+type playlistFileOptions struct {
+	formats []string
+}
+
+var playlistWriters = map[string]func(w io.Writer, title string, entries []*syncEntry) error{
+	"m3u8": writeM3U,
+	"xspf": writeXSPF,
+}
+
+func (po *playlistFileOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&po.formats, "playlist-file", []string{"m3u8"}, "Formats of the playlist file written with the videos: \"m3u8\", \"xspf\" or \"none\"")
+}
+
+func (po *playlistFileOptions) validate() error {
+	for _, f := range po.formats {
+		if _, ok := playlistWriters[f]; !ok && f != "none" {
+			return fmt.Errorf("unknown playlist file format %q", f)
+		}
+	}
+	return nil
+}
+
+// write writes a playlist file named after the playlist in dir for each
+// format. The files in the entries are relative to dir. A nil
+// playlistFileOptions writes nothing.
+func (po *playlistFileOptions) write(dir, title string, entries []*syncEntry) error {
+	if po == nil {
+		return nil
+	}
+	for _, format := range po.formats {
+		fn, ok := playlistWriters[format]
+		if !ok {
+			continue
+		}
+		if err := writeFile(filepath.Join(dir, playlistFileName(title)+"."+format), func(w io.Writer) error {
+			return fn(w, title, entries)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove removes the playlist files named after title from dir.
+func (po *playlistFileOptions) remove(dir, title string) {
+	if po == nil {
+		return
+	}
+	for format := range playlistWriters {
+		os.Remove(filepath.Join(dir, playlistFileName(title)+"."+format))
+	}
+}
+
+// playlistFileName returns the name of a playlist's files without the
+// extension, made safe the same way as the names of video files.
+func playlistFileName(title string) string {
+	if name := strings.TrimSpace(youtube.SafeFileName(title)); name != "" {
+		return name
+	}
+	return "playlist"
+}
+
+func writeFile(name string, fn func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err = fn(w); err == nil {
+		err = w.Flush()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// writeM3U writes an extended m3u playlist.
+func writeM3U(w io.Writer, title string, entries []*syncEntry) error {
+	line := strings.NewReplacer("\r", " ", "\n", " ")
+	fmt.Fprintf(w, "#EXTM3U\n#PLAYLIST:%s\n", line.Replace(title))
+	for _, e := range entries {
+		duration := e.Duration
+		if duration <= 0 {
+			duration = -1
+		}
+		fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", duration, line.Replace(e.Title), filepath.ToSlash(e.File))
+	}
+	return nil
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	NS      string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title"`
+	// Duration is in milliseconds
+	Duration int `xml:"duration,omitempty"`
+}
+
+// writeXSPF writes an xspf playlist.
+func writeXSPF(w io.Writer, title string, entries []*syncEntry) error {
+	p := xspfPlaylist{Version: "1", NS: "http://xspf.org/ns/0/", Title: title}
+	for _, e := range entries {
+		loc := &url.URL{Path: filepath.ToSlash(e.File)}
+		p.Tracks = append(p.Tracks, xspfTrack{
+			Location: loc.String(),
+			Title:    e.Title,
+			Duration: e.Duration * 1000,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
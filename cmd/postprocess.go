@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/pflag"
+)
+
+// postprocessor holds the options for everything that happens to a file
+// after it has been downloaded.
+type postprocessor struct {
+	splitChapters bool
+	embedChapters bool
+
+	writeThumbnail bool
+	embedThumbnail bool
+	thumbSize      string
+
+	writeInfoJSON bool
+}
+
+func (pp *postprocessor) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&pp.splitChapters, "split-chapters", false, "Split the download into one file per chapter (requires ffmpeg)")
+	flags.BoolVar(&pp.embedChapters, "embed-chapters", true, "Embed chapter markers into mp4 files when ffmpeg is available")
+	flags.BoolVar(&pp.writeThumbnail, "write-thumbnail", false, "Write the video's thumbnail next to the download")
+	flags.BoolVar(&pp.embedThumbnail, "embed-thumbnail", false, "Embed the video's thumbnail as cover art (requires ffmpeg)")
+	flags.StringVar(&pp.thumbSize, "thumb-size", "max", thumbSizeUsage)
+	flags.BoolVar(&pp.writeInfoJSON, "write-info-json", false, "Write the video's metadata to a .info.json file")
+}
+
+func (pp *postprocessor) validate() error {
+	_, _, err := parseThumbSize(pp.thumbSize)
+	return err
+}
+
+// run post-processes the downloaded file. The audio flag is true when the
+// file only contains audio and chosen is the stream that was downloaded,
+// which is nil when it is not known. A nil postprocessor does nothing.
+func (pp *postprocessor) run(v *youtube.Video, file string, audio bool, chosen *youtube.Stream) (err error) {
+	if pp == nil {
+		return nil
+	}
+	base := file[:len(file)-len(filepath.Ext(file))]
+	if pp.writeInfoJSON {
+		if err = v.SaveInfo(base+infoExt, chosen); err != nil {
+			return err
+		}
+	}
+	if pp.writeThumbnail {
+		if _, err = writeThumbnail(v, pp.thumbSize, base); err != nil {
+			return err
+		}
+	}
+	if pp.embedThumbnail {
+		if err = embedThumbnail(v, pp.thumbSize, file, audio); err != nil {
+			return err
+		}
+	}
+	if pp.splitChapters {
+		_, err = splitChapters(v, file)
+	} else if pp.embedChapters {
+		err = embedChapters(v, file)
+	}
+	return err
+}
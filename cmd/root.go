@@ -17,23 +17,22 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"log"
-	"net/url"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/harrybrwn/errs"
+	"github.com/harrybrwn/yt/pkg/logging"
 	"github.com/harrybrwn/yt/pkg/terminal"
 	"github.com/harrybrwn/yt/youtube"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	path   string // TODO: fix this!!!
-	cwd, _ = os.Getwd()
-
 	ytTemplate = `Usage:{{if .Runnable}}
   {{.UseLine}}{{end}}{{if gt (len .Aliases) 0}}
 
@@ -56,27 +55,180 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.
 `
 )
 
-// RootCommand returns the root command
+// options holds the flags shared by every command.
+type options struct {
+	path          string
+	proxy         string
+	sourceAddress string
+	ipv4, ipv6    bool
+	cookies       string
+	limitRate     string
+	limitEach     string
+	noColor       bool
+	quiet         bool
+	verbose       bool
+	debug         bool
+	dumpPages     string
+
+	client *youtube.Client
+	term   *terminal.Terminal
+	log    *logging.Logger
+}
+
+func (o *options) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.path, "path", "p", "", "Download path (default \"$PWD\")")
+	flags.StringVar(&o.proxy, "proxy", "", "Use an http, https or socks5 proxy (\"socks5://host:port\")")
+	flags.StringVar(&o.sourceAddress, "source-address", "", "Local ip address to make connections from")
+	flags.BoolVarP(&o.ipv4, "force-ipv4", "4", false, "Only make connections over IPv4")
+	flags.BoolVarP(&o.ipv6, "force-ipv6", "6", false, "Only make connections over IPv6")
+	flags.StringVar(&o.limitRate, "limit-rate", "", "Limit the download speed of all downloads together (\"500K\", \"2M\")")
+	flags.StringVar(&o.limitEach, "limit-rate-each", "", "Limit the download speed of each download")
+	flags.StringVar(&o.cookies, "cookies", "", "Netscape cookies.txt file used to access videos as a signed in account")
+	flags.BoolVar(&o.noColor, "no-color", false, "Do not use colors in the output")
+	flags.BoolVarP(&o.quiet, "quiet", "q", false, "Only print errors")
+	flags.BoolVarP(&o.verbose, "verbose", "v", false, "Print more details such as the streams being downloaded")
+	flags.BoolVar(&o.debug, "debug", false, "Print every request made to youtube")
+	flags.StringVar(&o.dumpPages, "dump-pages", "", "Save the pages and api responses from youtube to a directory for bug reports")
+}
+
+// newLogger creates the logger for the verbosity flags. Log lines go to
+// stderr and share the screen with any status rows when both stdout and
+// stderr are the terminal.
+func (o *options) newLogger(stderr io.Writer) *logging.Logger {
+	level := logging.Info
+	switch {
+	case o.debug:
+		level = logging.Debug
+	case o.verbose:
+		level = logging.Verbose
+	case o.quiet:
+		level = logging.Quiet
+	}
+	var w io.Writer = terminal.New(stderr)
+	if terminal.IsTerminal(stderr) && o.terminal().Interactive() {
+		w = o.terminal()
+	}
+	if o.noColor {
+		w.(*terminal.Terminal).SetColor(false)
+	}
+	return logging.New(w, level)
+}
+
+// newClient creates the youtube client from the network flags.
+func (o *options) newClient() (*youtube.Client, error) {
+	conf := &youtube.ClientConfig{
+		Proxy:         o.proxy,
+		SourceAddress: o.sourceAddress,
+		Logger:        o.log,
+	}
+	if o.dumpPages != "" {
+		conf.DumpPages = expandHome(o.dumpPages)
+	}
+	switch {
+	case o.ipv4 && o.ipv6:
+		return nil, errors.New("cannot force both IPv4 and IPv6")
+	case o.ipv4:
+		conf.IPVersion = 4
+	case o.ipv6:
+		conf.IPVersion = 6
+	}
+	var err error
+	if conf.RateLimit, err = parseRate(o.limitRate); err != nil {
+		return nil, err
+	}
+	if conf.DownloadRateLimit, err = parseRate(o.limitEach); err != nil {
+		return nil, err
+	}
+	if o.cookies != "" {
+		jar, err := youtube.LoadCookies(expandHome(o.cookies))
+		if err != nil {
+			return nil, err
+		}
+		conf.Jar = jar
+	}
+	return youtube.NewClient(conf)
+}
+
+// youtube returns the client used to make requests.
+func (o *options) youtube() *youtube.Client {
+	if o.client == nil {
+		return youtube.DefaultClient
+	}
+	return o.client
+}
+
+// terminal returns the terminal that a command writes its output to.
+func (o *options) terminal() *terminal.Terminal {
+	if o.term == nil {
+		o.term = terminal.New(os.Stdout)
+	}
+	return o.term
+}
+
+// progressTerminal returns the terminal that download progress is
+// written to, which drops everything with --quiet.
+func (o *options) progressTerminal() *terminal.Terminal {
+	if o.quiet {
+		return terminal.Plain(ioutil.Discard)
+	}
+	return o.terminal()
+}
+
+// dir returns the absolute path of the download directory.
+func (o *options) dir() (string, error) {
+	return filepath.Abs(o.path)
+}
+
+// RootCommand returns the root command. Each root command has its own
+// options so separate root commands can be run at the same time.
 func RootCommand() *cobra.Command {
+	opts := &options{}
 	var rootCmd = &cobra.Command{
 		Use:          "yt <command>",
 		Short:        "A cli tool for downloading youtube videos.",
 		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			conf, confErr := cmdConfig()
+			if confErr != nil {
+				if !configOptional(cmd) {
+					return confErr
+				}
+				// still apply the environment variables
+				conf = &config{}
+			}
+			if err := conf.apply(cmd); err != nil {
+				return err
+			}
+			opts.term = terminal.New(cmd.OutOrStdout())
+			if opts.noColor {
+				opts.term.SetColor(false)
+			}
+			opts.log = opts.newLogger(cmd.ErrOrStderr())
+			if confErr != nil {
+				opts.log.Warnf("ignoring the config file: %v", confErr)
+			}
+			var err error
+			opts.client, err = opts.newClient()
+			return err
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return errors.New("no Arguments\n\nUse \"yt help\" for more information")
 		},
 	}
-	versionCmd.Flags().BoolVarP(&verboseVersion, "verbose", "v", verboseVersion, "Show all version info")
-	rootCmd.PersistentFlags().StringVarP(&path, "path", "p", "", "Download path (default \"$PWD\")")
+	opts.addFlags(rootCmd.PersistentFlags())
 	rootCmd.SetUsageTemplate(ytTemplate)
 	rootCmd.AddCommand(
-		newDownloadCommand("video", "youtube videos", ".mp4"),
-		newDownloadCommand("audio", "audio from youtube videos", ".mpa"),
-		playlistCmd,
-		newinfoCmd(true),
-		testCmd,
-		versionCmd,
-		completionCmd,
+		newDownloadCommand(opts, "video", "youtube videos", ".mp4"),
+		newDownloadCommand(opts, "audio", "audio from youtube videos", ".mpa"),
+		newPlaylistCmd(opts),
+		newSyncCmd(opts),
+		newServeCmd(opts),
+		newThumbnailCmd(opts),
+		newInfoCmd(opts),
+		newConfigCmd(),
+		newTestCmd(),
+		newVersionCmd(),
+		newCompletionCmd(),
 	)
 	return rootCmd
 }
@@ -86,15 +238,19 @@ func RootCommand() *cobra.Command {
 func Execute() {
 	root := RootCommand()
 	if err := root.Execute(); err != nil {
+		var e *runError
+		if errors.As(err, &e) {
+			os.Exit(e.code)
+		}
 		os.Exit(1)
 	}
 }
 
-var (
-	version, builtBy, commit, date string
+var version, builtBy, commit, date string
 
-	verboseVersion = true
-	versionCmd     = &cobra.Command{
+func newVersionCmd() *cobra.Command {
+	verbose := true
+	c := &cobra.Command{
 		Use:   "version",
 		Short: "Show version info",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -104,7 +260,7 @@ var (
 				return
 			}
 			cmd.Printf("%s version %s\n", name, version)
-			if !verboseVersion {
+			if !verbose {
 				return
 			}
 			cmd.Printf("built by %s", builtBy)
@@ -117,8 +273,12 @@ var (
 			}
 		},
 	}
+	c.Flags().BoolVarP(&verbose, "verbose", "v", verbose, "Show all version info")
+	return c
+}
 
-	completionCmd = &cobra.Command{
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
 		Use:   "completion",
 		Short: "Print a completion script to stdout.",
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
@@ -142,7 +302,7 @@ var (
 		ValidArgs: []string{"zsh", "bash", "ps", "powershell", "fish"},
 		Aliases:   []string{"comp"},
 	}
-)
+}
 
 // SetInfo sets the version and compile info
 func SetInfo(v, built, cmt, dt string) {
@@ -152,9 +312,16 @@ func SetInfo(v, built, cmt, dt string) {
 	date = dt
 }
 
-type videoHandler func(v *youtube.Video) error
+type videoHandler func(v *youtube.Video, status *videoStatus) error
 
-func newDownloadCommand(name, short, defaultExt string) *cobra.Command {
+type videoLookup func(id string) (*youtube.Video, error)
+
+func newDownloadCommand(opts *options, name, short, defaultExt string) *cobra.Command {
+	pp := &postprocessor{}
+	live := &liveOptions{}
+	clip := &rangeOptions{}
+	dl := &downloadOptions{}
+	filters := &filterOptions{}
 	c := &cobra.Command{
 		Use:     fmt.Sprintf("%s [ids...]", name),
 		Short:   fmt.Sprintf("A tool for downloading %s", short),
@@ -165,110 +332,117 @@ func newDownloadCommand(name, short, defaultExt string) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			path, err = filepath.Abs(path)
+			if err = pp.validate(); err != nil {
+				return err
+			}
+			if err = filters.compile(); err != nil {
+				return err
+			}
+			infoFiles, err := cmd.Flags().GetStringSlice("load-info-json")
 			if err != nil {
 				return err
 			}
-			for i, arg := range args {
-				if isurl(arg) {
-					args[i] = getid(arg)
+			b := &batch{}
+			if file, err := cmd.Flags().GetString("batch-file"); err != nil {
+				return err
+			} else if file != "" {
+				if b, err = readBatchFile(file, cmd.InOrStdin()); err != nil {
+					return err
 				}
 			}
-
-			err = handleVideos(args, func(v *youtube.Video) (err error) {
-				p := filepath.Join(path, v.FileName) + ext
-				switch name {
-				case "audio":
-					err = v.DownloadAudio(p)
-				case "video":
-					err = v.Download(p)
-				default:
-					return errors.New("bad command name")
+			for _, arg := range args {
+				if isInfoFile(arg) {
+					b.addInfoFile(arg)
+				} else if err = b.add(arg); err != nil {
+					return err
 				}
-				cmd.Printf("\r%s \"%s\"\n", terminal.Green("Downloaded"), v.FileName+ext)
+			}
+			for _, file := range infoFiles {
+				b.addInfoFile(file)
+			}
+			dir, err := opts.dir()
+			if err != nil {
 				return err
-			})
-			return err
+			}
+			term, err := dl.setup(opts, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			board := newStatusBoard(term, dl.events, opts.log)
+			defer board.stop()
+			t := &target{dir: dir, ext: ext, audio: name == "audio", downloadOptions: dl, client: opts.youtube(), term: term, board: board, filter: filters, pp: pp}
+			if len(b.videos) > 0 || len(b.playlists) == 0 {
+				err = handleVideos(board, b.videos, b.lookup(opts.youtube(), live.lookup(opts)), func(v *youtube.Video, status *videoStatus) (err error) {
+					if err = filters.check(v, dl.format, name == "audio"); err != nil {
+						return err
+					}
+					release := dl.acquire()
+					defer release()
+					ext := ext
+					if v.IsLive && !cmd.Flags().Changed("extension") {
+						ext = ".ts"
+					}
+					p, err := dl.fileName(v, dir, ext)
+					if err != nil {
+						return err
+					}
+					start, end, isClip, err := clip.clipRange(v, b.starts)
+					if err != nil {
+						return err
+					}
+					status.start(p)
+					var chosen *youtube.Stream
+					switch {
+					case isClip:
+						chosen, err = downloadClip(v, p, start, end, name == "audio", dl, status)
+					case v.IsLive && name == "audio":
+						return errors.New("cannot record only the audio of a live stream")
+					case v.IsLive:
+						err = v.DownloadLive(p, live.options())
+					case name == "audio" || name == "video":
+						chosen, err = dl.download(v, p, name == "audio", status)
+					default:
+						return errors.New("bad command name")
+					}
+					if err != nil {
+						return err
+					}
+					term.Println("%s \"%s\"", term.Green("Downloaded"), filepath.Base(p))
+					if isClip {
+						v = clipChapters(v, start, end)
+					}
+					return pp.run(v, p, name == "audio", chosen)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			downloadPlaylists(b.playlists, t)
+			return board.finish()
 		},
 	}
 	flags := c.Flags()
 	flags.StringP("extension", "e", defaultExt, "File extension used for video download")
+	flags.String("batch-file", "", "Read urls or ids from a file, one per line ('-' for stdin)")
+	flags.StringSlice("load-info-json", nil, "Download from a saved .info.json file instead of querying youtube")
+	pp.addFlags(flags)
+	live.addFlags(flags)
+	clip.addFlags(flags)
+	dl.addFlags(flags)
+	filters.addFlags(flags)
 	return c
 }
 
 const loadingInterval = time.Second / 5
 
-func handleVideos(ids []string, fn videoHandler) (err error) {
+// handleVideos calls fn with each video at the same time. The result of
+// each video is kept by the board.
+func handleVideos(board *statusBoard, ids []string, lookup videoLookup, fn videoHandler) error {
 	if len(ids) == 0 {
 		return errors.New("no Arguments\n\nUse \"yt [command] --help\" for more information about a command")
 	}
-	setCursorOnHandler()
-	quit := make(chan struct{})
-	terminal.CursorOff()
-	defer terminal.CursorOn()
-
-	if len(ids) > 1 {
-		go func() {
-			err = asyncDownload(ids, fn)
-			close(quit)
-		}()
-	} else if len(ids) == 1 {
-		go func() {
-			var v *youtube.Video
-			defer close(quit)
-			v, err = youtube.NewVideo(ids[0])
-			if err != nil {
-				print("\r")
-				return
-			}
-			err = fn(v)
-		}()
-	}
-	for i := 0; ; i++ {
-		select {
-		case <-quit:
-			return err
-		default:
-			fmt.Printf("\r%s...  %c", terminal.Red("Downloading"), getLoadingChar(i))
-			time.Sleep(loadingInterval)
-		}
-	}
-}
-
-func newinfoCmd(hidden bool) *cobra.Command {
-	type infocommand struct {
-		fflags, playerResp bool
-	}
-	ic := infocommand{false, false}
-
-	infoCmd := &cobra.Command{
-		Use:   "info",
-		Short: "Get extra information for a youtube video",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			info, err := youtube.GetInfo(args[0])
-			if err != nil {
-				return err
-			}
-			if ic.fflags {
-				return printfflags(info)
-			}
-			if ic.playerResp {
-				fmt.Printf("%s\n", info["player_response"])
-				return nil
-			}
-			for k, v := range info {
-				if k == "player_response" || k == "fflags" {
-					continue
-				}
-				fmt.Printf("%s: %s\n", k, v[0])
-			}
-			return nil
-		},
-		Hidden: hidden,
-	}
-	infoCmd.Flags().BoolVar(&ic.fflags, "fflags", ic.fflags, "Print out the fflags")
-	infoCmd.Flags().BoolVar(&ic.playerResp, "player-response", ic.playerResp, "Print out the raw player response data")
-	return infoCmd
+	asyncDownload(board, ids, lookup, fn)
+	return nil
 }
 
 func getLoadingChar(i int) rune {
@@ -286,57 +460,34 @@ func getLoadingChar(i int) rune {
 	}
 }
 
-func printLoadingChar(i int) {
-	fmt.Printf("\b%c", getLoadingChar(i))
-}
-
-func printfflags(info map[string][][]byte) error {
-	f, ok := info["fflags"]
-	if !ok || len(f) == 0 {
-		return errors.New("could not find fflags")
-	}
-	data := string(f[0])
-
-	res, err := url.ParseQuery(data)
-	if err != nil {
-		return err
-	}
-	for k, v := range res {
-		fmt.Println(k, v)
-	}
-	return nil
-}
-
-func asyncDownload(ids []string, fn videoHandler) (err error) {
+func asyncDownload(board *statusBoard, ids []string, lookup videoLookup, fn videoHandler) {
 	var wg sync.WaitGroup
 	wg.Add(len(ids))
 	for _, id := range ids {
 		go func(id string) {
 			defer wg.Done()
-			if isurl(id) {
-				id = getid(id)
+			status := board.add(id)
+			defer status.done()
+			v, err := lookup(id)
+			if err == nil {
+				status.fetched(v)
+				err = fn(v, status)
 			}
-			v, err := youtube.NewVideo(id)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-			if e := fn(v); e != nil {
-				log.Println(e)
-				if err == nil {
-					err = e
-				}
+			// a single error is returned by the command instead
+			if status.finish(err) && len(ids) > 1 {
+				board.log.Errorf("%s: %v", status.id, err)
 			}
 		}(id)
 	}
 	wg.Wait()
-	return err
 }
 
-var testCmd = &cobra.Command{
-	Use:    "test",
-	Hidden: true,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return nil
-	},
+func newTestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "test",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
 }
@@ -0,0 +1,583 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/harrybrwn/yt/pkg/logging"
+	"github.com/harrybrwn/yt/pkg/terminal"
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/cobra"
+)
+
+const queueFile = ".yt-queue.json"
+
+func newServeCmd(opts *options) *cobra.Command {
+	var (
+		addr  string
+		token string
+		queue string
+		dl    = &downloadOptions{}
+		files = &playlistFileOptions{}
+	)
+	c := &cobra.Command{
+		Use:   "serve",
+		Short: "Run an http server that downloads videos for other machines",
+		Long: `Run an http server that downloads videos for other machines.
+
+Every request needs the token in an "Authorization: Bearer <token>" header
+or a "token" query parameter. A random token is printed at startup when
+--token is not given. The queue of jobs is kept in a file so that queued
+and unfinished jobs are started again when the server restarts.
+
+Endpoints:
+  GET    /api/jobs             list the jobs and their progress
+  POST   /api/jobs             queue a download: {"url": "...", "audio": false, "format": "", "filter": ""}
+  GET    /api/jobs/<id>        show a job
+  DELETE /api/jobs/<id>        cancel a job
+  GET    /api/videos/<id>      video metadata
+  GET    /api/playlists/<id>   playlist or channel metadata
+  GET    /api/files/<file>     download the file of a finished video in a job`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := files.validate(); err != nil {
+				return err
+			}
+			dir, err := opts.dir()
+			if err != nil {
+				return err
+			}
+			if token == "" {
+				if token, err = randomID(16); err != nil {
+					return err
+				}
+				cmd.Printf("token: %s\n", token)
+			}
+			if queue == "" {
+				queue = filepath.Join(dir, queueFile)
+			}
+			dl.log = opts.log
+			s, err := newServer(dir, queue, token, opts.youtube(), dl, files, opts.log)
+			if err != nil {
+				return err
+			}
+			srv := &http.Server{Addr: addr, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+			quit := make(chan struct{})
+			go s.run(quit)
+			go func() {
+				sig := make(chan os.Signal, 1)
+				signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+				<-sig
+				close(quit)
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				srv.Shutdown(ctx)
+			}()
+			opts.log.Infof("listening on %s", addr)
+			if err = srv.ListenAndServe(); err != http.ErrServerClosed {
+				return err
+			}
+			return s.save()
+		},
+	}
+	flags := c.Flags()
+	flags.StringVar(&addr, "addr", "127.0.0.1:8080", "Address to listen on")
+	flags.StringVar(&token, "token", "", "Token that clients must send (default is a random token)")
+	flags.StringVar(&queue, "queue-file", "", "File that the job queue is kept in (default is "+queueFile+" in the download path)")
+	dl.addFlags(flags)
+	files.addFlags(flags)
+	return c
+}
+
+// Job states
+const (
+	jobQueued   = "queued"
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+// job is a video or playlist download queued on the server.
+type job struct {
+	ID       string      `json:"id"`
+	URL      string      `json:"url"`
+	Audio    bool        `json:"audio,omitempty"`
+	Format   string      `json:"format,omitempty"`
+	Filter   string      `json:"filter,omitempty"`
+	State    string      `json:"state"`
+	Error    string      `json:"error,omitempty"`
+	Created  time.Time   `json:"created"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Videos   []*jobVideo `json:"videos,omitempty"`
+
+	board *statusBoard
+}
+
+// jobVideo is the progress of one video of a job.
+type jobVideo struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	State string `json:"state"`
+	// File is relative to the download path.
+	File   string `json:"file,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	Total  int64  `json:"total,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// server runs the jobs queued over http one at a time.
+type server struct {
+	dir    string
+	file   string
+	token  string
+	client *youtube.Client
+	dl     *downloadOptions
+	files  *playlistFileOptions
+	log    *logging.Logger
+
+	mu   sync.Mutex
+	jobs []*job
+	wake chan struct{}
+}
+
+// newServer creates a server and loads the job queue. Jobs that were
+// running when the server stopped are queued again.
+func newServer(dir, file, token string, client *youtube.Client, dl *downloadOptions, files *playlistFileOptions, log *logging.Logger) (*server, error) {
+	s := &server{
+		dir:    dir,
+		file:   file,
+		token:  token,
+		client: client,
+		dl:     dl,
+		files:  files,
+		log:    log,
+		wake:   make(chan struct{}, 1),
+	}
+	raw, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(raw, &s.jobs); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for _, j := range s.jobs {
+		if j.State == jobRunning {
+			j.State, j.Videos = jobQueued, nil
+		}
+	}
+	return s, nil
+}
+
+// save writes the job queue. It must not be called with s.mu held.
+func (s *server) save() error {
+	s.mu.Lock()
+	raw, err := json.MarshalIndent(s.jobs, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return replaceFile(s.file, raw)
+}
+
+func (s *server) saveOrLog() {
+	if err := s.save(); err != nil {
+		s.log.Errorf("could not save the job queue: %v", err)
+	}
+}
+
+// run starts the queued jobs in order until quit is closed.
+func (s *server) run(quit <-chan struct{}) {
+	for {
+		if j := s.next(); j != nil {
+			s.runJob(j)
+			continue
+		}
+		select {
+		case <-quit:
+			return
+		case <-s.wake:
+		}
+	}
+}
+
+// next marks the first queued job as running and returns it.
+func (s *server) next() *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.State == jobQueued {
+			j.State = jobRunning
+			return j
+		}
+	}
+	return nil
+}
+
+func (s *server) runJob(j *job) {
+	s.log.Infof("job %s: downloading %s", j.ID, j.URL)
+	err := s.download(j)
+	s.mu.Lock()
+	now := time.Now()
+	j.Finished = &now
+	switch {
+	case j.State == jobCanceled:
+	case err != nil:
+		j.State, j.Error = jobFailed, err.Error()
+	default:
+		j.State = jobDone
+	}
+	s.log.Infof("job %s: %s", j.ID, j.State)
+	s.mu.Unlock()
+	s.saveOrLog()
+}
+
+// download runs a job using the same code as the download commands with
+// its events going to the job's progress.
+func (s *server) download(j *job) error {
+	u, err := youtube.ParseURL(j.URL)
+	if err != nil {
+		return err
+	}
+	filters := &filterOptions{match: j.Filter}
+	if err = filters.compile(); err != nil {
+		return err
+	}
+	dl := &downloadOptions{
+		output:      s.dl.output,
+		format:      s.dl.format,
+		concurrency: s.dl.concurrency,
+		retries:     s.dl.retries,
+		log:         s.log,
+		events:      newEventFunc(func(e event) { s.update(j, e) }),
+	}
+	if j.Format != "" {
+		dl.format = j.Format
+	}
+	term := terminal.Plain(ioutil.Discard)
+	board := newBoard(term, dl.events, s.log)
+	defer board.stop()
+	s.mu.Lock()
+	j.board = board
+	canceled := j.State == jobCanceled
+	s.mu.Unlock()
+	if canceled {
+		board.cancel()
+	}
+
+	t := &target{dir: s.dir, ext: ".mp4", audio: j.Audio, downloadOptions: dl, client: s.client, term: term, board: board, items: &itemOptions{}, filter: filters, playlistFiles: s.files}
+	if j.Audio {
+		t.ext = ".mpa"
+	}
+	if u.IsPlaylist() || u.IsChannel() {
+		downloadPlaylists([]*youtube.URL{u}, t)
+	} else {
+		asyncDownload(board, []string{u.VideoID}, s.client.NewVideo, t.downloadOne)
+	}
+	return board.finish()
+}
+
+// update records a download event in the job's progress.
+func (s *server) update(j *job, e event) {
+	s.mu.Lock()
+	var v *jobVideo
+	for _, jv := range j.Videos {
+		if jv.ID == e.ID {
+			v = jv
+			break
+		}
+	}
+	if v == nil {
+		v = &jobVideo{ID: e.ID}
+		j.Videos = append(j.Videos, v)
+	}
+	if e.Title != "" {
+		v.Title = e.Title
+	}
+	if e.File != "" {
+		if rel, err := filepath.Rel(s.dir, e.File); err == nil {
+			v.File = filepath.ToSlash(rel)
+		}
+	}
+	switch e.Event {
+	case eventQueued, eventFetched:
+		v.State = jobQueued
+	case eventStarted, eventProgress:
+		v.State = jobRunning
+		v.Bytes, v.Total = e.Bytes, e.Total
+	case eventCompleted:
+		v.State, v.Bytes = jobDone, e.Bytes
+	case eventFailed:
+		v.State, v.Error = jobFailed, e.Error
+	case eventSkipped:
+		v.State, v.Reason = "skipped", e.Reason
+	}
+	s.mu.Unlock()
+	if e.Event != eventProgress {
+		s.saveOrLog()
+	}
+}
+
+// add queues a new job.
+func (s *server) add(j *job) error {
+	u, err := youtube.ParseURL(j.URL)
+	if err != nil {
+		return err
+	}
+	if !u.IsVideo() && !u.IsPlaylist() && !u.IsChannel() {
+		return fmt.Errorf("%q is not a video, playlist or channel", j.URL)
+	}
+	if j.Filter != "" {
+		if _, err = parseFilter(j.Filter); err != nil {
+			return err
+		}
+	}
+	if j.ID, err = randomID(8); err != nil {
+		return err
+	}
+	j.State, j.Error, j.Created, j.Finished, j.Videos = jobQueued, "", time.Now(), nil, nil
+	s.mu.Lock()
+	s.jobs = append(s.jobs, j)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return s.save()
+}
+
+// cancel stops a job. Running downloads stop the next time they write.
+func (s *server) cancel(id string) (*job, error) {
+	s.mu.Lock()
+	j := s.find(id)
+	if j == nil {
+		s.mu.Unlock()
+		return nil, errNotFound
+	}
+	switch j.State {
+	case jobQueued, jobRunning:
+		j.State = jobCanceled
+		if j.board != nil {
+			j.board.cancel()
+		}
+	default:
+		s.mu.Unlock()
+		return nil, fmt.Errorf("job is already %s", j.State)
+	}
+	s.mu.Unlock()
+	return j, s.save()
+}
+
+// find returns the job with an id. It must be called with s.mu held.
+func (s *server) find(id string) *job {
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+var errNotFound = errors.New("not found")
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/jobs", s.handleJobs)
+	mux.HandleFunc("/api/jobs/", s.handleJob)
+	mux.HandleFunc("/api/videos/", s.handleVideo)
+	mux.HandleFunc("/api/playlists/", s.handlePlaylist)
+	mux.HandleFunc("/api/files/", s.handleFile)
+	return s.auth(mux)
+}
+
+// auth rejects requests without the server's token.
+func (s *server) auth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		jobs := s.jobs
+		if jobs == nil {
+			jobs = []*job{}
+		}
+		s.writeJobs(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		j := &job{}
+		if err := json.NewDecoder(r.Body).Decode(j); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.add(j); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.mu.Lock()
+		s.writeJobs(w, http.StatusCreated, j)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		j := s.find(id)
+		if j == nil {
+			s.mu.Unlock()
+			writeError(w, http.StatusNotFound, errNotFound)
+			return
+		}
+		s.writeJobs(w, http.StatusOK, j)
+	case http.MethodDelete:
+		j, err := s.cancel(id)
+		if err == errNotFound {
+			writeError(w, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		s.mu.Lock()
+		s.writeJobs(w, http.StatusOK, j)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// handleFile serves the files of the finished videos in the jobs. Other
+// files in the download path are not served.
+func (s *server) handleFile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/files/")
+	s.mu.Lock()
+	found := s.finishedFile(name)
+	s.mu.Unlock()
+	if !found {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(name)))
+	if err != nil {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// finishedFile returns true if a job has finished downloading the file.
+// It must be called with s.mu held.
+func (s *server) finishedFile(name string) bool {
+	for _, j := range s.jobs {
+		for _, v := range j.Videos {
+			if v.File == name && v.State == jobDone {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *server) handleVideo(w http.ResponseWriter, r *http.Request) {
+	// only ids and links are looked up, never files on the server
+	u, err := youtube.ParseURL(strings.TrimPrefix(r.URL.Path, "/api/videos/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !u.IsVideo() {
+		writeError(w, http.StatusBadRequest, errors.New("not a video"))
+		return
+	}
+	v, err := s.client.NewVideo(u.VideoID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *server) handlePlaylist(w http.ResponseWriter, r *http.Request) {
+	u, err := youtube.ParseURL(strings.TrimPrefix(r.URL.Path, "/api/playlists/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !u.IsPlaylist() && !u.IsChannel() {
+		writeError(w, http.StatusBadRequest, errors.New("not a playlist or channel"))
+		return
+	}
+	p, err := lookupPlaylist(s.client, u)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// writeJobs writes jobs that are shared with the downloads. It must be
+// called with s.mu held and unlocks it before writing so that a slow
+// client does not block the downloads.
+func (s *server) writeJobs(w http.ResponseWriter, status int, v interface{}) {
+	raw, err := json.Marshal(v)
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, status, json.RawMessage(raw))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func randomID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
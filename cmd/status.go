@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/harrybrwn/yt/pkg/logging"
+	"github.com/harrybrwn/yt/pkg/terminal"
+	"github.com/harrybrwn/yt/youtube"
+)
+
+// statusBoard shows a status row for each video that is being downloaded
+// at the same time, reports each change to the event log and keeps the
+// result of every video for the summary at the end of a run.
+type statusBoard struct {
+	term   *terminal.Terminal
+	dash   *terminal.Dashboard
+	events *eventLog
+	log    *logging.Logger
+	mu     sync.Mutex
+	active map[*videoStatus]struct{}
+
+	// results, protected by mu
+	succeeded int
+	skipped   []result
+	failed    []result
+
+	once       sync.Once
+	quit       chan struct{}
+	signals    chan os.Signal
+	cancelOnce sync.Once
+	canceled   chan struct{}
+}
+
+type result struct {
+	name   string
+	reason string
+	err    error
+}
+
+// newStatusBoard takes over the terminal until stop or finish is called.
+// An interrupt prints the summary of the run so far and exits.
+func newStatusBoard(term *terminal.Terminal, events *eventLog, log *logging.Logger) *statusBoard {
+	term.CursorOff()
+	b := newBoard(term, events, log)
+	b.signals = make(chan os.Signal, 1)
+	signal.Notify(b.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-b.quit:
+		case sig := <-b.signals:
+			b.term.Println("Stopped.")
+			b.finish()
+			os.Exit(interruptCode(sig))
+		}
+	}()
+	return b
+}
+
+// interruptCode is the exit code used by shells for a process killed by
+// a signal.
+func interruptCode(sig os.Signal) int {
+	if sig == syscall.SIGTERM {
+		return 128 + int(syscall.SIGTERM)
+	}
+	return 128 + int(syscall.SIGINT)
+}
+
+// newBoard creates a status board without touching the cursor or the
+// signal handlers so that it can be used by a long running process.
+func newBoard(term *terminal.Terminal, events *eventLog, log *logging.Logger) *statusBoard {
+	b := &statusBoard{
+		term:     term,
+		dash:     term.Dashboard(),
+		events:   events,
+		log:      log,
+		active:   make(map[*videoStatus]struct{}),
+		quit:     make(chan struct{}),
+		canceled: make(chan struct{}),
+	}
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-b.quit:
+				return
+			case <-time.After(loadingInterval):
+				b.spin(i)
+			}
+		}
+	}()
+	return b
+}
+
+// videoStatus follows a single video from when it is queued until it is
+// downloaded or fails.
+type videoStatus struct {
+	b     *statusBoard
+	row   *terminal.Row
+	id    string
+	title string
+	file  string
+	began time.Time
+
+	// protected by b.mu
+	started      bool
+	bytes, total int64
+	reported     time.Time
+}
+
+// add queues a video. The video gets a row once its metadata is fetched.
+func (b *statusBoard) add(id string) *videoStatus {
+	b.events.emit(event{Event: eventQueued, ID: id})
+	return &videoStatus{b: b, id: id}
+}
+
+// spin redraws every row with the spinner at frame i.
+func (b *statusBoard) spin(i int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.active {
+		s.draw(getLoadingChar(i))
+	}
+}
+
+// stop clears the rows and gives the terminal back.
+func (b *statusBoard) stop() {
+	b.once.Do(func() {
+		if b.signals != nil {
+			signal.Stop(b.signals)
+		}
+		close(b.quit)
+		b.dash.Stop()
+		b.term.CursorOn()
+	})
+}
+
+var errCanceled = errors.New("download canceled")
+
+// cancel makes every download on the board fail with errCanceled the next
+// time it writes.
+func (b *statusBoard) cancel() {
+	b.cancelOnce.Do(func() { close(b.canceled) })
+}
+
+// Exit codes for runs where videos failed to download.
+const (
+	exitPartialFailure = 2
+	exitTotalFailure   = 3
+)
+
+// runError is returned when some or all of the videos in a run failed.
+type runError struct {
+	code int
+	err  error
+}
+
+func (e *runError) Error() string { return e.err.Error() }
+
+// finish stops the board, prints a summary of a run with more than one
+// video and returns an error if any of them failed.
+func (b *statusBoard) finish() error {
+	b.stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := b.succeeded + len(b.skipped) + len(b.failed)
+	if total > 1 {
+		b.log.Infof("%d downloaded, %d skipped, %d failed", b.succeeded, len(b.skipped), len(b.failed))
+		for _, r := range b.skipped {
+			b.log.Infof("  skipped %s: %s", r.name, r.reason)
+		}
+		for _, r := range b.failed {
+			b.log.Infof("  failed %s: %s", r.name, r.reason)
+		}
+	}
+	switch {
+	case len(b.failed) == 0:
+		return nil
+	case total == 1:
+		return &runError{code: exitTotalFailure, err: b.failed[0].err}
+	case b.succeeded == 0:
+		return &runError{code: exitTotalFailure, err: fmt.Errorf("no videos were downloaded, %d failed", len(b.failed))}
+	}
+	return &runError{code: exitPartialFailure, err: fmt.Errorf("%d of %d videos failed", len(b.failed), total)}
+}
+
+// fetched shows a row for the video with its title.
+func (s *videoStatus) fetched(v *youtube.Video) {
+	s.id, s.title = v.ID, v.Title
+	s.b.events.emit(event{
+		Event:    eventFetched,
+		ID:       s.id,
+		Title:    s.title,
+		Playlist: v.PlaylistTitle,
+		Index:    v.PlaylistIndex,
+	})
+	s.b.mu.Lock()
+	s.row = s.b.dash.AddRow()
+	s.b.active[s] = struct{}{}
+	s.draw(' ')
+	s.b.mu.Unlock()
+}
+
+// start marks the video as downloading to a file.
+func (s *videoStatus) start(file string) {
+	s.file, s.began = file, time.Now()
+	s.b.events.emit(event{Event: eventStarted, ID: s.id, Title: s.title, File: file})
+	s.b.mu.Lock()
+	s.started = true
+	s.draw(' ')
+	s.b.mu.Unlock()
+}
+
+const progressInterval = time.Second / 2
+
+// progress records the number of bytes downloaded out of the total. The
+// total is zero if it is not known.
+func (s *videoStatus) progress(n, total int64) {
+	s.b.mu.Lock()
+	s.bytes, s.total = n, total
+	report := time.Since(s.reported) >= progressInterval || (total > 0 && n == total)
+	if report {
+		s.reported = time.Now()
+	}
+	s.b.mu.Unlock()
+	if report {
+		s.b.events.emit(event{Event: eventProgress, ID: s.id, Title: s.title, File: s.file, Bytes: n, Total: total})
+	}
+}
+
+// writer wraps w so that everything written to it is counted as the
+// video's progress.
+func (s *videoStatus) writer(w io.Writer, total int64) io.Writer {
+	return &progressWriter{w: w, s: s, total: total}
+}
+
+func (s *videoStatus) completed() {
+	s.b.mu.Lock()
+	s.b.succeeded++
+	s.b.mu.Unlock()
+	e := event{Event: eventCompleted, ID: s.id, Title: s.title, File: s.file}
+	if info, err := os.Stat(s.file); err == nil && !info.IsDir() {
+		e.Bytes = info.Size()
+	}
+	s.b.events.emit(e)
+	if !s.began.IsZero() {
+		s.b.log.Verbosef("%s: downloaded %d bytes in %v", s.id, e.Bytes, time.Since(s.began).Round(time.Millisecond))
+	}
+}
+
+// finish records the result of a video and returns true if it failed.
+func (s *videoStatus) finish(err error) bool {
+	var skip *skipError
+	switch {
+	case err == nil:
+		s.completed()
+	case errors.As(err, &skip):
+		s.skip(skip.reason)
+	default:
+		s.failed(err)
+		return true
+	}
+	return false
+}
+
+// skip records that the video was not downloaded on purpose.
+func (s *videoStatus) skip(reason string) {
+	s.b.mu.Lock()
+	s.b.skipped = append(s.b.skipped, result{name: s.name(), reason: reason})
+	s.b.mu.Unlock()
+	s.b.events.emit(event{Event: eventSkipped, ID: s.id, Title: s.title, Reason: reason})
+	s.b.log.Verbosef("%s: skipped, %s", s.name(), reason)
+}
+
+func (s *videoStatus) name() string {
+	if s.title == "" {
+		return s.id
+	}
+	return fmt.Sprintf("%s (%s)", s.id, s.title)
+}
+
+func (s *videoStatus) failed(err error) {
+	s.b.mu.Lock()
+	s.b.failed = append(s.b.failed, result{name: s.name(), reason: err.Error(), err: err})
+	s.b.mu.Unlock()
+	s.b.events.emit(event{
+		Event:     eventFailed,
+		ID:        s.id,
+		Title:     s.title,
+		File:      s.file,
+		Error:     err.Error(),
+		ErrorType: errorType(err),
+	})
+}
+
+// done removes the video's row.
+func (s *videoStatus) done() {
+	s.b.mu.Lock()
+	delete(s.b.active, s)
+	s.b.mu.Unlock()
+	if s.row != nil {
+		s.row.Remove()
+	}
+}
+
+// draw sets the text of the row. It must be called with b.mu held.
+func (s *videoStatus) draw(spinner rune) {
+	t := s.b.term
+	if !s.started {
+		s.row.Set("%s %s", t.Yellow("Waiting"), s.title)
+		return
+	}
+	if s.total > 0 {
+		s.row.Set("%s %s %3d%% %c", t.Red("Downloading"), s.title, s.bytes*100/s.total, spinner)
+		return
+	}
+	s.row.Set("%s %s %c", t.Red("Downloading"), s.title, spinner)
+}
+
+type progressWriter struct {
+	w     io.Writer
+	s     *videoStatus
+	n     int64
+	total int64
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	select {
+	case <-pw.s.b.canceled:
+		return 0, errCanceled
+	default:
+	}
+	n, err := pw.w.Write(p)
+	pw.n += int64(n)
+	pw.s.progress(pw.n, pw.total)
+	return n, err
+}
@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// syncStateFile is the name of the file in a mirror directory that keeps
+// track of which videos have been downloaded.
+const syncStateFile = ".yt-sync.json"
+
+// syncOptions holds what to do with the files of videos that were removed
+// from a playlist.
+type syncOptions struct {
+	remove  bool
+	archive string
+}
+
+func (so *syncOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&so.remove, "remove", false, "Delete the files of videos that were removed from the playlist")
+	flags.StringVar(&so.archive, "archive", "", "Move the files of videos that were removed from the playlist to this directory (relative to the mirror)")
+}
+
+func newSyncCmd(opts *options) *cobra.Command {
+	var (
+		ext     string
+		audio   bool
+		so      = &syncOptions{}
+		dl      = &downloadOptions{}
+		filters = &filterOptions{}
+		files   = &playlistFileOptions{}
+	)
+	c := &cobra.Command{
+		Use:   "sync <playlist|channel...>",
+		Short: "Keep a directory in sync with a playlist or channel",
+		Long: `Keep a directory in sync with a playlist or channel.
+
+Each playlist is mirrored in a directory named after it. Only videos
+that are new or whose files are missing are downloaded, files are
+renamed when the title of their video changes and the files of removed
+videos are kept unless --remove or --archive is given. Nothing is
+removed when youtube lists fewer videos than the mirror has because the
+list may be incomplete. The state of a
+mirror is kept in a ` + syncStateFile + ` file inside of it and a playlist
+file listing the videos in order is rewritten after every sync.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if so.remove && so.archive != "" {
+				return fmt.Errorf("--remove and --archive cannot be used together")
+			}
+			lists := make([]*youtube.URL, 0, len(args))
+			for _, arg := range args {
+				u, err := youtube.ParseURL(arg)
+				if err != nil {
+					return err
+				}
+				if !u.IsPlaylist() && !u.IsChannel() {
+					return fmt.Errorf("%q is not a playlist or channel", arg)
+				}
+				lists = append(lists, u)
+			}
+			if err := filters.compile(); err != nil {
+				return err
+			}
+			if err := files.validate(); err != nil {
+				return err
+			}
+			dir, err := opts.dir()
+			if err != nil {
+				return err
+			}
+			term, err := dl.setup(opts, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			board := newStatusBoard(term, dl.events, opts.log)
+			defer board.stop()
+			t := &target{dir: dir, ext: ext, audio: audio, downloadOptions: dl, client: opts.youtube(), term: term, board: board, items: &itemOptions{}, filter: filters, playlistFiles: files}
+			if audio {
+				t.ext = ".mpa"
+			}
+			for _, u := range lists {
+				if err = syncPlaylist(u, t, so); err != nil {
+					t.board.add(listID(u)).failed(err)
+					t.log.Errorf("%v", err)
+				}
+			}
+			return board.finish()
+		},
+	}
+	flags := c.Flags()
+	flags.BoolVarP(&audio, "audio", "a", false, "download the audio from all the videos in the playlist")
+	flags.StringVarP(&ext, "extension", "e", ".mp4", "file extension used for video download")
+	so.addFlags(flags)
+	dl.addFlags(flags)
+	filters.addFlags(flags)
+	files.addFlags(flags)
+	return c
+}
+
+// syncState is the state of a mirror directory. It maps the ids of the
+// videos that have been downloaded to their files.
+type syncState struct {
+	ID      string                `json:"id"`
+	Title   string                `json:"title"`
+	Updated time.Time             `json:"updated"`
+	Videos  map[string]*syncEntry `json:"videos"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// syncEntry is a downloaded video.
+type syncEntry struct {
+	// File is relative to the mirror directory.
+	File     string `json:"file"`
+	Title    string `json:"title"`
+	Index    int    `json:"index"`
+	Duration int    `json:"duration"`
+	// Removed is set when the video is no longer in the playlist.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// loadMirror finds the mirror of a playlist in root. The mirror is looked
+// for in a directory named after the playlist and then in every directory
+// of root in case the playlist was renamed. A new state is returned when
+// there is no mirror.
+func loadMirror(root, id, title string) (*syncState, error) {
+	dir, err := playlistDir(root, title, id)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{dir}
+	matches, err := filepath.Glob(filepath.Join(root, "*", syncStateFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range matches {
+		dirs = append(dirs, filepath.Dir(m))
+	}
+	for _, dir := range dirs {
+		st, err := readSyncState(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if st.ID == id {
+			return st, nil
+		}
+	}
+	return &syncState{
+		ID:     id,
+		Title:  title,
+		Videos: make(map[string]*syncEntry),
+		dir:    dir,
+	}, nil
+}
+
+func readSyncState(dir string) (*syncState, error) {
+	raw, err := ioutil.ReadFile(filepath.Join(dir, syncStateFile))
+	if err != nil {
+		return nil, err
+	}
+	st := &syncState{dir: dir}
+	if err = json.Unmarshal(raw, st); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Join(dir, syncStateFile), err)
+	}
+	if st.Videos == nil {
+		st.Videos = make(map[string]*syncEntry)
+	}
+	return st, nil
+}
+
+// save writes the state file.
+func (st *syncState) save() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.Updated = time.Now()
+	raw, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return replaceFile(filepath.Join(st.dir, syncStateFile), raw)
+}
+
+// replaceFile writes a file by renaming a temporary file over it so that
+// it is never left half written.
+func replaceFile(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// set records the file of a video and saves the state.
+func (st *syncState) set(id string, e *syncEntry) error {
+	st.mu.Lock()
+	st.Videos[id] = e
+	st.mu.Unlock()
+	return st.save()
+}
+
+// path returns the full path of a file in the mirror.
+func (st *syncState) path(file string) string {
+	return filepath.Join(st.dir, file)
+}
+
+// entries returns the videos that are still in the playlist in playlist
+// order.
+func (st *syncState) entries() []*syncEntry {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list := make([]*syncEntry, 0, len(st.Videos))
+	for _, e := range st.Videos {
+		if !e.Removed {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Index < list[j].Index })
+	return list
+}
+
+// syncPlaylist brings the mirror of a playlist up to date and waits for
+// the new videos to be downloaded.
+func syncPlaylist(u *youtube.URL, t *target, so *syncOptions) error {
+	plst, err := lookupPlaylist(t.client, u)
+	if err != nil {
+		return err
+	}
+	st, err := loadMirror(t.dir, listID(u), plst.Title)
+	if err != nil {
+		return err
+	}
+	oldTitle := st.Title
+	if err = st.rename(t.dir, plst.Title, t); err != nil {
+		return err
+	}
+	if oldTitle != st.Title {
+		t.playlistFiles.remove(st.dir, oldTitle)
+	}
+	if err = os.MkdirAll(st.dir, 0755); err != nil {
+		return err
+	}
+
+	// youtube only sends the first page of a long playlist and sometimes
+	// an empty one so a list shorter than the mirror is not trusted
+	// with removing anything
+	mirrored := len(st.entries())
+	complete := len(plst.Videos) > 0 && len(plst.Videos) >= mirrored
+
+	upstream := make(map[string]bool, len(plst.Videos))
+	var missing []int
+	for i, pv := range plst.Videos {
+		upstream[pv.ID] = true
+		e, ok := st.Videos[pv.ID]
+		if !ok || !exists(st.path(e.File)) {
+			missing = append(missing, i+1)
+			continue
+		}
+		e.Index, e.Removed = i+1, false
+		if e.Title != pv.Title {
+			if err = st.renameVideo(pv.ID, e, pv.Title, t); err != nil {
+				t.log.Warnf("%s: could not rename %s: %v", pv.ID, e.File, err)
+			}
+		}
+	}
+	for id, e := range st.Videos {
+		if upstream[id] {
+			continue
+		}
+		if !complete {
+			t.log.Warnf("youtube listed %d videos in %q but the mirror has %d, not removing any of them", len(plst.Videos), plst.Title, mirrored)
+			break
+		}
+		st.removed(id, e, t, so)
+	}
+	if err = st.save(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	downloadItems(plst, missing, st.dir, t, &wg, func(v *youtube.Video, name string) {
+		file, err := filepath.Rel(st.dir, name)
+		if err != nil {
+			file = name
+		}
+		e := &syncEntry{File: file, Title: v.Title, Index: v.PlaylistIndex, Duration: plst.Videos[v.PlaylistIndex-1].LengthSeconds}
+		if err = st.set(v.ID, e); err != nil {
+			t.log.Errorf("%v", err)
+		}
+	})
+	wg.Wait()
+	return st.writePlaylistFiles(t.playlistFiles)
+}
+
+// writePlaylistFiles writes the playlist files of the mirror with every
+// video that is still in the playlist and has been downloaded.
+func (st *syncState) writePlaylistFiles(po *playlistFileOptions) error {
+	var entries []*syncEntry
+	for _, e := range st.entries() {
+		if exists(st.path(e.File)) {
+			entries = append(entries, e)
+		}
+	}
+	return po.write(st.dir, st.Title, entries)
+}
+
+// rename moves the mirror to a directory named after the playlist when
+// the playlist's title has changed.
+func (st *syncState) rename(root, title string, t *target) error {
+	if st.Title == title {
+		return nil
+	}
+	dir, err := playlistDir(root, title, st.ID)
+	if err != nil {
+		return err
+	}
+	st.Title = title
+	if st.dir == dir || !exists(st.dir) || exists(dir) {
+		return nil
+	}
+	if err := os.Rename(st.dir, dir); err != nil {
+		return err
+	}
+	t.term.Println("%s %s -> %s", t.term.Yellow("Renamed"), st.dir, dir)
+	st.dir = dir
+	return nil
+}
+
+// renameVideo renames the file of a video whose title has changed.
+func (st *syncState) renameVideo(id string, e *syncEntry, title string, t *target) error {
+	v, err := t.client.NewVideo(id)
+	if err != nil {
+		return err
+	}
+	e.Title = title
+	name, err := t.fileName(v, st.dir, filepath.Ext(e.File))
+	if err != nil {
+		return err
+	}
+	old := st.path(e.File)
+	if name == old {
+		return nil
+	}
+	if exists(name) {
+		return fmt.Errorf("%s already exists", name)
+	}
+	if err = os.Rename(old, name); err != nil {
+		return err
+	}
+	if e.File, err = filepath.Rel(st.dir, name); err != nil {
+		return err
+	}
+	t.term.Println("%s %s -> %s", t.term.Yellow("Renamed"), old, name)
+	return nil
+}
+
+// removed handles a video that is no longer in the playlist.
+func (st *syncState) removed(id string, e *syncEntry, t *target, so *syncOptions) {
+	file := st.path(e.File)
+	switch {
+	case so.remove:
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			t.log.Warnf("%s: %v", id, err)
+			return
+		}
+		t.term.Println("%s %s", t.term.Yellow("Removed"), file)
+	case so.archive != "":
+		dir := so.archive
+		if !filepath.IsAbs(dir) {
+			dir = st.path(dir)
+		}
+		name := filepath.Join(dir, filepath.Base(e.File))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.log.Warnf("%s: %v", id, err)
+			return
+		}
+		if err := os.Rename(file, name); err != nil && !os.IsNotExist(err) {
+			t.log.Warnf("%s: %v", id, err)
+			return
+		}
+		t.term.Println("%s %s -> %s", t.term.Yellow("Archived"), file, name)
+	default:
+		if !e.Removed {
+			t.log.Infof("%s (%s) was removed from the playlist, keeping %s", id, e.Title, file)
+		}
+		e.Removed = true
+		return
+	}
+	delete(st.Videos, id)
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
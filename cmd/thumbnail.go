@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/harrybrwn/yt/pkg/ffmpeg"
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/cobra"
+)
+
+const thumbSizeUsage = "Thumbnail size to use: max, medium or WxH"
+
+func newThumbnailCmd(opts *options) *cobra.Command {
+	var size = "max"
+	c := &cobra.Command{
+		Use:     "thumbnail [ids...]",
+		Short:   "Download youtube video thumbnails",
+		Aliases: []string{"thumb"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, _, err := parseThumbSize(size); err != nil {
+				return err
+			}
+			dir, err := opts.dir()
+			if err != nil {
+				return err
+			}
+			for i, arg := range args {
+				if args[i], err = videoID(arg); err != nil {
+					return err
+				}
+			}
+			term := opts.progressTerminal()
+			board := newStatusBoard(term, nil, opts.log)
+			defer board.stop()
+			err = handleVideos(board, args, opts.lookupVideo, func(v *youtube.Video, status *videoStatus) error {
+				status.start(filepath.Join(dir, v.FileName))
+				file, err := writeThumbnail(v, size, filepath.Join(dir, v.FileName))
+				if err != nil {
+					return err
+				}
+				term.Println("%s \"%s\"", term.Green("Downloaded"), filepath.Base(file))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return board.finish()
+		},
+	}
+	c.Flags().StringVar(&size, "thumb-size", size, thumbSizeUsage)
+	return c
+}
+
+// parseThumbSize parses a thumbnail size given as "max", "medium" or "WxH".
+// A size of zero means the largest available thumbnail.
+func parseThumbSize(size string) (w, h int, err error) {
+	switch size {
+	case "max", "":
+		return 0, 0, nil
+	case "medium":
+		return 320, 180, nil
+	}
+	parts := strings.SplitN(strings.ToLower(size), "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid thumbnail size %q", size)
+	}
+	if w, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid thumbnail width %q", parts[0])
+	}
+	if h, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, fmt.Errorf("invalid thumbnail height %q", parts[1])
+	}
+	return w, h, nil
+}
+
+// thumbnailCandidates returns the thumbnails to try in order, starting with
+// the one that best matches the size and falling back to smaller ones.
+func thumbnailCandidates(v *youtube.Video, size string) ([]youtube.Thumbnail, error) {
+	w, h, err := parseThumbSize(size)
+	if err != nil {
+		return nil, err
+	}
+	var t *youtube.Thumbnail
+	if w == 0 && h == 0 {
+		t = v.BestThumbnail()
+	} else {
+		t = v.ThumbnailFor(w, h)
+	}
+	if t == nil {
+		return nil, errors.New("video has no thumbnails")
+	}
+	var candidates []youtube.Thumbnail
+	for i := len(v.Thumbnails) - 1; i >= 0; i-- {
+		if len(candidates) > 0 || v.Thumbnails[i] == *t {
+			candidates = append(candidates, v.Thumbnails[i])
+		}
+	}
+	return candidates, nil
+}
+
+// writeThumbnail downloads the thumbnail closest to size to base plus the
+// image's extension and returns the file name.
+func writeThumbnail(v *youtube.Video, size, base string) (string, error) {
+	candidates, err := thumbnailCandidates(v, size)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range candidates {
+		file := base + t.Ext()
+		if err = t.Download(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("could not download thumbnail: %v", err)
+}
+
+// embedThumbnail downloads a thumbnail to a temporary file and attaches it
+// to the media file.
+func embedThumbnail(v *youtube.Video, size, file string, audio bool) error {
+	dir, err := ioutil.TempDir("", "yt-thumbnail")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	image, err := writeThumbnail(v, size, filepath.Join(dir, "thumbnail"))
+	if err != nil {
+		return err
+	}
+	videoStreams := 1
+	if audio {
+		videoStreams = 0
+	}
+	return ffmpeg.EmbedThumbnail(file, image, videoStreams)
+}
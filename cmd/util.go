@@ -3,32 +3,40 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/signal"
-	"regexp"
-	"syscall"
 
-	"github.com/harrybrwn/yt/pkg/terminal"
+	"github.com/harrybrwn/yt/youtube"
 )
 
-var youtubeRegex = regexp.MustCompile(`^((?:https?:)?\/\/)?((?:www|m)\.)?((?:youtube\.com|youtu.be))(\/(?:[\w\-]+\?v=|embed\/|v\/)?)([\w\-]+)(\S+)?$`)
-
-func isurl(s string) bool {
-	return youtubeRegex.MatchString(s)
+// videoID returns the video id from any kind of youtube link or id. The
+// names of saved info files are returned unchanged.
+func videoID(arg string) (string, error) {
+	if isInfoFile(arg) {
+		return arg, nil
+	}
+	u, err := youtube.ParseURL(arg)
+	if err != nil {
+		return "", err
+	}
+	if !u.IsVideo() {
+		return "", fmt.Errorf("%q is not a video", arg)
+	}
+	return u.VideoID, nil
 }
 
-func getid(url string) string {
-	groups := youtubeRegex.FindAllStringSubmatch(url, -1)
-	return groups[0][5]
-}
+const infoExt = ".info.json"
 
-func setCursorOnHandler() {
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		fmt.Println("\rStopped.          ")
-		terminal.CursorOn()
-		os.Exit(0)
-	}()
+// isInfoFile returns true if the argument names a saved info file instead
+// of a video. Any file is treated as an info file whatever its name is.
+func isInfoFile(arg string) bool {
+	info, err := os.Stat(arg)
+	return err == nil && info.Mode().IsRegular()
+}
 
+// lookupVideo gets a video from youtube or from a saved info file if
+// the argument is the name of an info file.
+func (o *options) lookupVideo(id string) (*youtube.Video, error) {
+	if isInfoFile(id) {
+		return o.youtube().LoadInfo(id)
+	}
+	return o.youtube().NewVideo(id)
 }
@@ -5,4 +5,6 @@ go 1.13
 require (
 	github.com/harrybrwn/errs v0.0.1
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.3
+	gopkg.in/yaml.v2 v2.2.2
 )
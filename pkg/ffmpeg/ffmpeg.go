@@ -0,0 +1,165 @@
+// Package ffmpeg is a thin wrapper around the ffmpeg command line tool used
+// for post-processing downloaded files.
+package ffmpeg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Binary is the name or path of the ffmpeg executable.
+var Binary = "ffmpeg"
+
+// ErrNotFound is returned when the ffmpeg executable cannot be found.
+var ErrNotFound = errors.New("ffmpeg: could not find ffmpeg executable")
+
+// Available returns true if the ffmpeg executable can be found.
+func Available() bool {
+	_, err := exec.LookPath(Binary)
+	return err == nil
+}
+
+// Chapter is a named section of a media file.
+type Chapter struct {
+	Title      string
+	Start, End time.Duration
+}
+
+// Cut copies the section of the input file between start and end into
+// the output file without re-encoding. An end of zero will copy until the
+// end of the input.
+func Cut(in, out string, start, end time.Duration) error {
+	args := []string{"-ss", seconds(start), "-i", in}
+	if end > 0 {
+		args = append(args, "-t", seconds(end-start))
+	}
+	args = append(args, "-map", "0", "-c", "copy", out)
+	return run(args...)
+}
+
+// Input is an input file that is read starting from an offset.
+type Input struct {
+	File  string
+	Start time.Duration
+}
+
+// Trim combines the streams of the inputs into the output file keeping
+// only duration worth of media after each input's start. The streams are
+// re-encoded so that the cut is frame accurate. A duration of zero keeps
+// everything after the start.
+func Trim(inputs []Input, out string, duration time.Duration) error {
+	var args []string
+	for _, in := range inputs {
+		args = append(args, "-ss", seconds(in.Start), "-i", in.File)
+	}
+	for i := range inputs {
+		args = append(args, "-map", fmt.Sprintf("%d", i))
+	}
+	if duration > 0 {
+		args = append(args, "-t", seconds(duration))
+	}
+	args = append(args, out)
+	return run(args...)
+}
+
+// WriteChapters embeds chapter markers into a media file in place.
+func WriteChapters(file string, chapters []Chapter) error {
+	meta, err := ioutil.TempFile("", "yt-chapters")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(meta.Name())
+	_, err = meta.WriteString(metadata(chapters))
+	if e := meta.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return inPlace(file, func(tmp string) error {
+		return run(
+			"-i", file, "-i", meta.Name(),
+			"-map", "0", "-map_metadata", "1", "-map_chapters", "1",
+			"-c", "copy", tmp,
+		)
+	})
+}
+
+// EmbedThumbnail attaches an image to a media file in place as its cover
+// art. The videoStreams argument is the number of video streams already in
+// the file so that the image can be marked as an attached picture.
+func EmbedThumbnail(file, image string, videoStreams int) error {
+	return inPlace(file, func(tmp string) error {
+		return run(
+			"-i", file, "-i", image,
+			"-map", "0", "-map", "1", "-c", "copy",
+			fmt.Sprintf("-disposition:v:%d", videoStreams), "attached_pic",
+			tmp,
+		)
+	})
+}
+
+// inPlace runs fn with a temporary file name that has the same extension
+// as file and replaces file with the result if fn succeeds.
+func inPlace(file string, fn func(tmp string) error) error {
+	ext := filepath.Ext(file)
+	tmp := strings.TrimSuffix(file, ext) + ".tmp" + ext
+	if err := fn(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// metadata generates an ffmetadata file containing the chapters.
+func metadata(chapters []Chapter) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, c := range chapters {
+		b.WriteString("\n[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(&b, "START=%d\n", c.Start.Milliseconds())
+		fmt.Fprintf(&b, "END=%d\n", c.End.Milliseconds())
+		fmt.Fprintf(&b, "title=%s\n", escape(c.Title))
+	}
+	return b.String()
+}
+
+var metadataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`=`, `\=`,
+	`;`, `\;`,
+	`#`, `\#`,
+	"\n", "\\\n",
+)
+
+func escape(s string) string {
+	return metadataEscaper.Replace(s)
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func run(args ...string) error {
+	bin, err := exec.LookPath(Binary)
+	if err != nil {
+		return ErrNotFound
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(bin, append([]string{"-hide_banner", "-loglevel", "error", "-y"}, args...)...)
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg: %s", msg)
+		}
+		return fmt.Errorf("ffmpeg: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Package logging is a small leveled logger for command line output.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/harrybrwn/yt/pkg/terminal"
+)
+
+// Level is how much a logger writes.
+type Level int
+
+// Levels from least to most output.
+const (
+	// Quiet only writes errors.
+	Quiet Level = iota
+	// Info writes errors, warnings and normal messages.
+	Info
+	// Verbose also writes details such as which streams are used.
+	Verbose
+	// Debug also writes every request that is made.
+	Debug
+)
+
+// Logger writes messages at or below its level. A nil Logger writes
+// nothing.
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+	term  *terminal.Terminal
+}
+
+// New creates a logger. Prefixes are colored when w is a terminal that
+// supports colors.
+func New(w io.Writer, level Level) *Logger {
+	l := &Logger{w: w, level: level}
+	if t, ok := w.(*terminal.Terminal); ok {
+		l.term = t
+	} else {
+		l.term = terminal.New(w)
+	}
+	return l
+}
+
+// Level returns the logger's level.
+func (l *Logger) Level() Level {
+	if l == nil {
+		return Quiet
+	}
+	return l.level
+}
+
+// Errorf writes an error at every level.
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.logf(Quiet, "Error: ", format, a...)
+}
+
+// Warnf writes a warning unless the logger is quiet.
+func (l *Logger) Warnf(format string, a ...interface{}) {
+	l.logf(Info, "Warning: ", format, a...)
+}
+
+// Infof writes a message unless the logger is quiet.
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.logf(Info, "", format, a...)
+}
+
+// Verbosef writes a message at the verbose and debug levels.
+func (l *Logger) Verbosef(format string, a ...interface{}) {
+	l.logf(Verbose, "", format, a...)
+}
+
+// Debugf writes a message at the debug level.
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	l.logf(Debug, "debug: ", format, a...)
+}
+
+func (l *Logger) logf(level Level, prefix, format string, a ...interface{}) {
+	if l == nil || level > l.level {
+		return
+	}
+	switch level {
+	case Quiet:
+		prefix = l.term.Red(prefix)
+	case Debug:
+		prefix = l.term.Blue(prefix)
+	default:
+		if prefix != "" {
+			prefix = l.term.Yellow(prefix)
+		}
+	}
+	msg := strings.TrimRight(fmt.Sprintf(format, a...), "\n")
+	l.mu.Lock()
+	io.WriteString(l.w, prefix+msg+"\n")
+	l.mu.Unlock()
+}
@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	for _, tt := range []struct {
+		level Level
+		want  string
+	}{
+		{Quiet, "Error: e\n"},
+		{Info, "Error: e\nWarning: w\ni\n"},
+		{Verbose, "Error: e\nWarning: w\ni\nv\n"},
+		{Debug, "Error: e\nWarning: w\ni\nv\ndebug: d\n"},
+	} {
+		var buf bytes.Buffer
+		l := New(&buf, tt.level)
+		l.Errorf("e")
+		l.Warnf("w")
+		l.Infof("i\n")
+		l.Verbosef("v")
+		l.Debugf("d")
+		if buf.String() != tt.want {
+			t.Errorf("level %d: got %q, want %q", tt.level, buf.String(), tt.want)
+		}
+	}
+	var l *Logger
+	l.Errorf("a nil logger should not panic")
+}
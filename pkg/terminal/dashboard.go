@@ -0,0 +1,235 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const redrawInterval = time.Second / 10
+
+// Dashboard owns the bottom rows of a terminal and redraws a status row
+// for each task in place. Lines printed with the terminal's Println while
+// a dashboard is running scroll above the rows.
+type Dashboard struct {
+	t      *Terminal
+	mu     sync.Mutex
+	rows   []*Row
+	drawn  []int // visible width of the lines currently on the screen
+	width  int
+	height int
+	dirty  bool
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Row is a single status line of a dashboard.
+type Row struct {
+	d    *Dashboard
+	text string
+}
+
+// Dashboard starts a dashboard on the terminal. Nothing is drawn when
+// the terminal is not interactive. Stop must be called to clear the rows
+// and give the terminal back.
+func (t *Terminal) Dashboard() *Dashboard {
+	d := &Dashboard{
+		t:    t,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	d.width, d.height = t.Size()
+	if !t.ansi {
+		close(d.done)
+		return d
+	}
+	t.mu.Lock()
+	t.dash = d
+	t.mu.Unlock()
+	go d.run()
+	return d
+}
+
+// AddRow adds a status row to the bottom of the dashboard.
+func (d *Dashboard) AddRow() *Row {
+	r := &Row{d: d}
+	d.mu.Lock()
+	d.rows = append(d.rows, r)
+	d.dirty = true
+	d.mu.Unlock()
+	return r
+}
+
+// Set changes the text of the row.
+func (r *Row) Set(format string, a ...interface{}) {
+	text := fmt.Sprintf(format, a...)
+	r.d.mu.Lock()
+	if text != r.text {
+		r.text = text
+		r.d.dirty = true
+	}
+	r.d.mu.Unlock()
+}
+
+// Remove takes the row off of the dashboard.
+func (r *Row) Remove() {
+	d := r.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, row := range d.rows {
+		if row == r {
+			d.rows = append(d.rows[:i], d.rows[i+1:]...)
+			d.dirty = true
+			return
+		}
+	}
+}
+
+// Stop clears the dashboard's rows from the screen.
+func (d *Dashboard) Stop() {
+	d.once.Do(func() {
+		close(d.stop)
+		<-d.done
+		if !d.t.ansi {
+			return
+		}
+		d.t.mu.Lock()
+		d.t.dash = nil
+		d.t.mu.Unlock()
+		d.mu.Lock()
+		var b strings.Builder
+		d.clear(&b)
+		d.t.write([]byte(b.String()))
+		d.mu.Unlock()
+	})
+}
+
+func (d *Dashboard) run() {
+	defer close(d.done)
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer signal.Stop(resize)
+	tick := time.NewTicker(redrawInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-resize:
+			d.mu.Lock()
+			d.width, d.height = d.t.Size()
+			d.redraw("")
+			d.mu.Unlock()
+		case <-tick.C:
+			d.mu.Lock()
+			if d.dirty {
+				d.redraw("")
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// log writes a line above the dashboard's rows.
+func (d *Dashboard) log(line string) {
+	d.mu.Lock()
+	d.redraw(line)
+	d.mu.Unlock()
+}
+
+// redraw clears the rows on the screen, writes the line above them and
+// draws the rows again. It must be called with d.mu held.
+func (d *Dashboard) redraw(line string) {
+	var b strings.Builder
+	d.clear(&b)
+	b.WriteString(line)
+	max := d.width - 1 // writing in the last column wraps on some terminals
+	rows := d.rows
+	// keep a line free so the rows never scroll off of the screen
+	if len(rows) >= d.height && d.height > 1 {
+		rows = rows[:d.height-2]
+	}
+	for _, r := range rows {
+		d.line(&b, r.text, max)
+	}
+	if more := len(d.rows) - len(rows); more > 0 {
+		d.line(&b, fmt.Sprintf("... and %d more", more), max)
+	}
+	d.dirty = false
+	d.t.write([]byte(b.String()))
+}
+
+func (d *Dashboard) line(b *strings.Builder, text string, width int) {
+	text, n := truncate(text, width)
+	b.WriteString(text)
+	b.WriteString("\n")
+	d.drawn = append(d.drawn, n)
+}
+
+// clear moves the cursor to the first drawn row and clears the screen
+// below it. Rows that were drawn wider than the current width have been
+// wrapped onto more than one line.
+func (d *Dashboard) clear(b *strings.Builder) {
+	var lines int
+	for _, n := range d.drawn {
+		lines++
+		if d.width > 0 && n > d.width {
+			lines += (n - 1) / d.width
+		}
+	}
+	b.WriteString("\r")
+	if lines > 0 {
+		fmt.Fprintf(b, "\033[%dA", lines)
+	}
+	b.WriteString("\033[J")
+	d.drawn = d.drawn[:0]
+}
+
+// truncate cuts s down to width visible characters, not counting color
+// codes, and returns the visible width of the result.
+func truncate(s string, width int) (string, int) {
+	var (
+		b       strings.Builder
+		n       int
+		escapes bool
+	)
+	for i := 0; i < len(s); {
+		if s[i] == '\033' {
+			end := strings.IndexFunc(s[i+1:], func(r rune) bool {
+				return r >= '@' && r <= '~' && r != '['
+			})
+			if end < 0 {
+				break
+			}
+			end += i + 2
+			b.WriteString(s[i:end])
+			escapes = true
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch r {
+		case '\r':
+			i += size
+			continue
+		case '\n', '\t':
+			r = ' '
+		}
+		if n >= width {
+			if escapes {
+				b.WriteString("\033[0m")
+			}
+			return b.String(), n
+		}
+		b.WriteRune(r)
+		n++
+		i += size
+	}
+	return b.String(), n
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package terminal
+
+import "os"
+
+func fileSize(f *os.File) (width, height int) { return 0, 0 }
+
+func notifyResize(c chan<- os.Signal) {}
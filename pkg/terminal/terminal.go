@@ -3,61 +3,162 @@ package terminal
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"runtime"
+	"strconv"
+	"sync"
 )
 
-var (
-	// Output is the default output for the terminal package
-	Output io.Writer
+// Terminal is an output that may or may not be an interactive terminal.
+// Colors, cursor control and status lines are only written when they are
+// supported so that output piped into a file stays plain text.
+type Terminal struct {
+	mu          sync.Mutex
+	w           io.Writer
+	file        *os.File
+	dash        *Dashboard
+	interactive bool
+	ansi        bool
+	color       bool
+}
 
-	controlChar string
-)
+// New creates a Terminal that writes to w. The terminal is interactive
+// when w is a terminal device. Colors are used on interactive terminals
+// unless the NO_COLOR environment variable is set or TERM is "dumb".
+func New(w io.Writer) *Terminal {
+	t := &Terminal{w: w, interactive: IsTerminal(w)}
+	if t.interactive {
+		t.file = w.(*os.File)
+	}
+	t.ansi = t.interactive && runtime.GOOS != "windows" && os.Getenv("TERM") != "dumb"
+	t.color = t.ansi && os.Getenv("NO_COLOR") == ""
+	return t
+}
+
+// Plain creates a Terminal that never writes colors or control codes.
+func Plain(w io.Writer) *Terminal {
+	return &Terminal{w: w}
+}
+
+// IsTerminal returns true if w is a terminal device.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Interactive returns true if the terminal can show status lines that
+// are replaced as they change.
+func (t *Terminal) Interactive() bool { return t.interactive }
+
+// Size returns the number of columns and lines in the terminal. The
+// COLUMNS and LINES environment variables or a default of 80x24 are used
+// when the size cannot be found.
+func (t *Terminal) Size() (width, height int) {
+	if t.file != nil {
+		width, height = fileSize(t.file)
+	}
+	if width <= 0 {
+		width = envInt("COLUMNS", 80)
+	}
+	if height <= 0 {
+		height = envInt("LINES", 24)
+	}
+	return width, height
+}
+
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// SetColor turns colors on or off. Colors are never written to outputs
+// that are not terminals.
+func (t *Terminal) SetColor(on bool) { t.color = on && t.ansi }
+
+// Write writes to the terminal's output. Writes are put above the rows of
+// a running dashboard.
+func (t *Terminal) Write(p []byte) (int, error) {
+	if d := t.dashboard(); d != nil {
+		d.log(string(p))
+		return len(p), nil
+	}
+	return t.write(p)
+}
+
+func (t *Terminal) write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.w.Write(p)
+}
+
+// Printf writes formatted text to the terminal's output.
+func (t *Terminal) Printf(format string, a ...interface{}) {
+	fmt.Fprintf(t, format, a...)
+}
 
-func init() {
-	switch runtime.GOOS {
-	case "windows":
-		Output = ioutil.Discard
-		controlChar = ""
-	default:
-		Output = os.Stdout
-		controlChar = "\033"
+// Status replaces the current status line on interactive terminals and
+// does nothing otherwise or while a dashboard is running.
+func (t *Terminal) Status(format string, a ...interface{}) {
+	if !t.interactive || t.dashboard() != nil {
+		return
 	}
+	t.write([]byte("\r" + fmt.Sprintf(format, a...) + t.control("K")))
+}
+
+// Println writes a line, replacing the status line on interactive
+// terminals. The line is written above the rows of a running dashboard.
+func (t *Terminal) Println(format string, a ...interface{}) {
+	line := fmt.Sprintf(format, a...) + "\n"
+	if d := t.dashboard(); d != nil {
+		d.log(line)
+		return
+	}
+	if t.interactive {
+		line = "\r" + t.control("K") + line
+	}
+	t.write([]byte(line))
 }
 
 // CursorOn turns the cursor on
-func CursorOn() { out(control("?25h")) }
+func (t *Terminal) CursorOn() { t.write([]byte(t.control("?25h"))) }
 
 // CursorOff turns the cursor off
-func CursorOff() { out(control("?25l")) }
+func (t *Terminal) CursorOff() { t.write([]byte(t.control("?25l"))) }
 
 // Blue returns s but colored blue
-func Blue(s string) string { return color(36, s) }
+func (t *Terminal) Blue(s string) string { return t.paint(36, s) }
 
 // Green returns s but colored green
-func Green(s string) string { return color(32, s) }
+func (t *Terminal) Green(s string) string { return t.paint(32, s) }
 
 // Red returns s but colored red
-func Red(s string) string { return color(31, s) }
+func (t *Terminal) Red(s string) string { return t.paint(31, s) }
 
 // Yellow returns s but colored yellow
-func Yellow(s string) string { return color(35, s) }
+func (t *Terminal) Yellow(s string) string { return t.paint(35, s) }
 
-func color(color int, s string) string {
-	if controlChar == "" {
-		return s
-	}
-	return fmt.Sprintf("%[1]s[%dm%s%[1]s[0m", controlChar, color, s)
+func (t *Terminal) dashboard() *Dashboard {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dash
 }
 
-func out(s string) {
-	fmt.Fprint(Output, s)
+func (t *Terminal) paint(color int, s string) string {
+	if !t.color {
+		return s
+	}
+	return fmt.Sprintf("\033[%dm%s\033[0m", color, s)
 }
 
-func control(code string) string {
-	if controlChar == "" {
+func (t *Terminal) control(code string) string {
+	if !t.ansi {
 		return ""
 	}
-	return fmt.Sprintf("%s[%s", controlChar, code)
+	return "\033[" + code
 }
@@ -0,0 +1,119 @@
+package terminal
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestPlainOutput(t *testing.T) {
+	var buf bytes.Buffer
+	term := New(&buf)
+	if term.Interactive() {
+		t.Error("a buffer should not be an interactive terminal")
+	}
+	term.CursorOff()
+	term.Status("Downloading... %c", '|')
+	term.Println("%s %s", term.Green("Downloaded"), "video.mp4")
+	term.CursorOn()
+	if buf.String() != "Downloaded video.mp4\n" {
+		t.Errorf("got %q", buf.String())
+	}
+	term.SetColor(true)
+	if s := term.Red("error"); s != "error" {
+		t.Errorf("colors should not be written to a buffer: %q", s)
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	term := &Terminal{w: &bytes.Buffer{}, interactive: true, ansi: true, color: true}
+	if s := term.Blue("x"); s != "\033[36mx\033[0m" {
+		t.Errorf("got %q", s)
+	}
+	term.SetColor(false)
+	if s := term.Blue("x"); s != "x" {
+		t.Errorf("got %q", s)
+	}
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+	if term = New(f); term.color {
+		t.Error("NO_COLOR should turn colors off")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		in, out string
+		width   int
+	}{
+		{"hello world", "hello", 5},
+		{"short", "short", 10},
+		{"\033[31mred\033[0m text", "\033[31mred\033[0m \033[0m", 4},
+		{"héllo", "hé", 2},
+		{"a\nb\rc", "a bc", 10},
+	} {
+		out, n := truncate(tt.in, tt.width)
+		if out != tt.out {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.width, out, tt.out)
+		}
+		if n > tt.width {
+			t.Errorf("truncate(%q, %d) is %d wide", tt.in, tt.width, n)
+		}
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	var buf bytes.Buffer
+	term := &Terminal{w: &buf, interactive: true, ansi: true}
+	d := &Dashboard{t: term, width: 10, height: 4}
+	term.dash = d
+	a, b := d.AddRow(), d.AddRow()
+	a.Set("first row is long")
+	b.Set("second")
+	d.redraw("")
+	if got, want := buf.String(), "\r\033[Jfirst row\nsecond\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	buf.Reset()
+	term.Println("done")
+	if got, want := buf.String(), "\r\033[2A\033[Jdone\nfirst row\nsecond\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	a.Remove()
+	d.AddRow().Set("third")
+	d.AddRow().Set("fourth")
+	d.height = 3
+	d.redraw("")
+	if got, want := buf.String(), "\r\033[2A\033[Jsecond\n... and 2\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// the screen got narrower so the rows were wrapped
+	buf.Reset()
+	d.width = 4
+	d.redraw("")
+	if !bytes.HasPrefix(buf.Bytes(), []byte("\r\033[5A")) {
+		t.Errorf("wrapped rows were not cleared: %q", buf.String())
+	}
+}
+
+func TestPlainDashboard(t *testing.T) {
+	var buf bytes.Buffer
+	term := New(&buf)
+	d := term.Dashboard()
+	d.AddRow().Set("downloading")
+	term.Println("done")
+	d.Stop()
+	d.Stop()
+	if buf.String() != "done\n" {
+		t.Errorf("got %q", buf.String())
+	}
+}
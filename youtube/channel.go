@@ -0,0 +1,84 @@
+package youtube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	channelIDRegex     = regexp.MustCompile(`^UC[\w-]{22}$`)
+	channelIDPageRegex = regexp.MustCompile(`"(?:channelId|externalId|browseId)":"(UC[\w-]{22})"`)
+)
+
+// IsChannelID returns true if s has the form of a channel id.
+func IsChannelID(s string) bool {
+	return channelIDRegex.MatchString(s)
+}
+
+// ChannelPlaylist returns the playlist containing every video uploaded by
+// a channel. The channel may be a channel id, a handle such as "@name" or
+// a legacy "c/name" or "user/name" path.
+func ChannelPlaylist(channel string) (*Playlist, error) {
+	return DefaultClient.ChannelPlaylist(channel)
+}
+
+// ChannelPlaylist returns the playlist of a channel's uploads.
+func (c *Client) ChannelPlaylist(channel string) (*Playlist, error) {
+	id, err := c.ChannelID(channel)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewPlaylist(UploadsPlaylistID(id))
+}
+
+// UploadsPlaylistID returns the id of the playlist containing all of a
+// channel's uploads given the channel id.
+func UploadsPlaylistID(channelID string) string {
+	return "UU" + strings.TrimPrefix(channelID, "UC")
+}
+
+// ChannelID resolves a channel handle or custom url path to the
+// channel's id.
+func ChannelID(channel string) (string, error) {
+	return DefaultClient.ChannelID(channel)
+}
+
+// ChannelID resolves a channel handle or custom url path to the
+// channel's id.
+func (c *Client) ChannelID(channel string) (string, error) {
+	if IsChannelID(channel) {
+		return channel, nil
+	}
+	path := strings.Trim(channel, "/")
+	if !strings.HasPrefix(path, "@") && !strings.HasPrefix(path, "c/") && !strings.HasPrefix(path, "user/") {
+		path = "@" + path
+	}
+	resp, err := c.get(fmt.Sprintf("https://%s/%s", host, path))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not find channel %s: %s", channel, resp.Status)
+	}
+	page, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	id := findChannelID(page)
+	if id == "" {
+		return "", fmt.Errorf("could not find channel id for %s", channel)
+	}
+	return id, nil
+}
+
+func findChannelID(page []byte) string {
+	match := channelIDPageRegex.FindSubmatch(page)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
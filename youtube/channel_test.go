@@ -0,0 +1,27 @@
+package youtube
+
+import "testing"
+
+func TestChannelID(t *testing.T) {
+	id := "UCBR8-60-B28hp2BmDPdntcQ"
+	if !IsChannelID(id) {
+		t.Errorf("%s should be a channel id", id)
+	}
+	if IsChannelID("@YouTube") || IsChannelID("PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo") {
+		t.Error("only channel ids should be channel ids")
+	}
+	got, err := ChannelID(id)
+	if err != nil || got != id {
+		t.Errorf("channel ids should not need to be resolved: got %q, %v", got, err)
+	}
+	if pl := UploadsPlaylistID(id); pl != "UUBR8-60-B28hp2BmDPdntcQ" {
+		t.Errorf("wrong uploads playlist: %s", pl)
+	}
+	page := []byte(`<html><script>var ytInitialData = {"metadata":{"channelMetadataRenderer":{"title":"YouTube","externalId":"UCBR8-60-B28hp2BmDPdntcQ"}}};</script>`)
+	if got = findChannelID(page); got != id {
+		t.Errorf("could not find channel id in page: got %q", got)
+	}
+	if got = findChannelID([]byte("<html></html>")); got != "" {
+		t.Errorf("expected no channel id, got %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package youtube
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chapter is a section of a video marked by the chapter markers on the
+// video's progress bar or by a timestamp in the video's description.
+type Chapter struct {
+	Title string
+	// FileName is a file system safe version of the chapter's title.
+	FileName string
+	Start    time.Duration
+	// End is the start of the next chapter or the end of the video
+	// for the last chapter.
+	End time.Duration
+}
+
+type jsonChapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// MarshalJSON encodes the chapter with its times in seconds.
+func (c Chapter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonChapter{
+		Title: c.Title,
+		Start: c.Start.Seconds(),
+		End:   c.End.Seconds(),
+	})
+}
+
+// UnmarshalJSON decodes a chapter encoded with MarshalJSON.
+func (c *Chapter) UnmarshalJSON(b []byte) error {
+	var jc jsonChapter
+	if err := json.Unmarshal(b, &jc); err != nil {
+		return err
+	}
+	c.Title = jc.Title
+	c.FileName = SafeFileName(jc.Title)
+	c.Start = time.Duration(jc.Start * float64(time.Second))
+	c.End = time.Duration(jc.End * float64(time.Second))
+	return nil
+}
+
+var chapterRegex = regexp.MustCompile(`^\s*[\(\[]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\)\]]?\s*(?:[-–—:|.]\s*)?(.+?)\s*$`)
+
+// parseChapters finds the chapter markers in a video description. Youtube
+// only treats the timestamps as chapters if the first one starts at zero and
+// they are in ascending order so the same rules are applied here.
+func parseChapters(description string, length time.Duration) []Chapter {
+	var chapters []Chapter
+	for _, line := range strings.Split(description, "\n") {
+		match := chapterRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		start, ok := parseTimestamp(match[1])
+		if !ok {
+			continue
+		}
+		if len(chapters) == 0 && start != 0 {
+			return nil
+		}
+		if n := len(chapters); n > 0 && start <= chapters[n-1].Start {
+			return nil
+		}
+		title := strings.TrimSpace(match[2])
+		chapters = append(chapters, Chapter{
+			Title:    title,
+			FileName: SafeFileName(title),
+			Start:    start,
+		})
+	}
+	return setChapterEnds(chapters, length)
+}
+
+// setChapterEnds ends each chapter at the start of the next one and the
+// last one at the end of the video. Returns nil if there are less than
+// two chapters.
+func setChapterEnds(chapters []Chapter, length time.Duration) []Chapter {
+	if len(chapters) < 2 {
+		return nil
+	}
+	for i := 0; i < len(chapters)-1; i++ {
+		chapters[i].End = chapters[i+1].Start
+	}
+	last := &chapters[len(chapters)-1]
+	last.End = length
+	if last.End < last.Start {
+		last.End = last.Start
+	}
+	return chapters
+}
+
+// playerChapters is the part of the player response holding the chapter
+// markers shown on the video's progress bar.
+type playerChapters struct {
+	PlayerOverlayRenderer struct {
+		DecoratedPlayerBarRenderer struct {
+			DecoratedPlayerBarRenderer struct {
+				PlayerBar struct {
+					MultiMarkersPlayerBarRenderer struct {
+						MarkersMap []struct {
+							Key   string `json:"key"`
+							Value struct {
+								Chapters []struct {
+									ChapterRenderer struct {
+										Title struct {
+											SimpleText string `json:"simpleText"`
+											Runs       []struct {
+												Text string `json:"text"`
+											} `json:"runs"`
+										} `json:"title"`
+										TimeRangeStartMillis int64 `json:"timeRangeStartMillis"`
+									} `json:"chapterRenderer"`
+								} `json:"chapters"`
+							} `json:"value"`
+						} `json:"markersMap"`
+					} `json:"multiMarkersPlayerBarRenderer"`
+				} `json:"playerBar"`
+			} `json:"decoratedPlayerBarRenderer"`
+		} `json:"decoratedPlayerBarRenderer"`
+	} `json:"playerOverlayRenderer"`
+}
+
+// chapters returns the first list of chapter markers in the player
+// response. Returns nil if there are no markers or they are out of order.
+func (pc *playerChapters) chapters(length time.Duration) []Chapter {
+	bar := pc.PlayerOverlayRenderer.DecoratedPlayerBarRenderer.DecoratedPlayerBarRenderer.PlayerBar
+	for _, markers := range bar.MultiMarkersPlayerBarRenderer.MarkersMap {
+		if len(markers.Value.Chapters) == 0 {
+			continue
+		}
+		var chapters []Chapter
+		for _, c := range markers.Value.Chapters {
+			r := c.ChapterRenderer
+			title := r.Title.SimpleText
+			if title == "" {
+				for _, run := range r.Title.Runs {
+					title += run.Text
+				}
+			}
+			title = strings.TrimSpace(title)
+			start := time.Duration(r.TimeRangeStartMillis) * time.Millisecond
+			if n := len(chapters); n > 0 && start <= chapters[n-1].Start {
+				return nil
+			}
+			chapters = append(chapters, Chapter{
+				Title:    title,
+				FileName: SafeFileName(title),
+				Start:    start,
+			})
+		}
+		return setChapterEnds(chapters, length)
+	}
+	return nil
+}
+
+// parseTimestamp parses timestamps of the form "h:mm:ss" or "m:ss".
+func parseTimestamp(s string) (time.Duration, bool) {
+	var d time.Duration
+	parts := strings.Split(s, ":")
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, false
+		}
+		if i > 0 && n >= 60 {
+			return 0, false
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second, true
+}
@@ -0,0 +1,97 @@
+package youtube
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseChapters(t *testing.T) {
+	desc := `A great mix.
+
+Tracklist:
+0:00 Intro
+1:30 - First Song
+(1:02:03) Second: Song
+check out my other videos`
+	chapters := parseChapters(desc, 2*time.Hour)
+	if len(chapters) != 3 {
+		t.Fatalf("wrong number of chapters: got %d, want 3", len(chapters))
+	}
+	tests := []Chapter{
+		{Title: "Intro", Start: 0, End: 90 * time.Second},
+		{Title: "First Song", Start: 90 * time.Second, End: time.Hour + 2*time.Minute + 3*time.Second},
+		{Title: "Second: Song", Start: time.Hour + 2*time.Minute + 3*time.Second, End: 2 * time.Hour},
+	}
+	for i, tt := range tests {
+		c := chapters[i]
+		if c.Title != tt.Title {
+			t.Errorf("wrong title: got %q, want %q", c.Title, tt.Title)
+		}
+		if c.Start != tt.Start || c.End != tt.End {
+			t.Errorf("wrong chapter bounds for %q: got %v-%v, want %v-%v", c.Title, c.Start, c.End, tt.Start, tt.End)
+		}
+	}
+	if chapters[2].FileName != "Second Song" {
+		t.Errorf("chapter file name should be file system safe, got %q", chapters[2].FileName)
+	}
+}
+
+func TestParseChapters_Invalid(t *testing.T) {
+	for _, desc := range []string{
+		"",
+		"no timestamps here",
+		"0:00 only one chapter",
+		"0:30 does not start at zero\n1:00 second",
+		"0:00 first\n2:00 second\n1:00 out of order",
+		"0:00 first\n1:75 bad seconds",
+	} {
+		if c := parseChapters(desc, time.Hour); c != nil {
+			t.Errorf("expected no chapters from %q, got %v", desc, c)
+		}
+	}
+}
+
+func TestPlayerChapters(t *testing.T) {
+	markers := `"playerOverlays": {"playerOverlayRenderer": {"decoratedPlayerBarRenderer": {"decoratedPlayerBarRenderer": {"playerBar": {
+		"multiMarkersPlayerBarRenderer": {"markersMap": [{"key": "DESCRIPTION_CHAPTERS", "value": {"chapters": [
+			{"chapterRenderer": {"title": {"simpleText": "Intro"}, "timeRangeStartMillis": 0}},
+			{"chapterRenderer": {"title": {"runs": [{"text": "Part "}, {"text": "1/2"}]}, "timeRangeStartMillis": 90500}}
+		]}}]}}}}}}`
+	details := func(desc string) string {
+		return `"videoDetails": {"lengthSeconds": "600", "shortDescription": "` + desc + `"}`
+	}
+
+	var v Video
+	if err := initVideoData([]byte(`{`+details("no timestamps")+`, `+markers+`}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	want := []Chapter{
+		{Title: "Intro", FileName: "Intro", Start: 0, End: 90500 * time.Millisecond},
+		{Title: "Part 1/2", FileName: SafeFileName("Part 1/2"), Start: 90500 * time.Millisecond, End: 10 * time.Minute},
+	}
+	if len(v.Chapters) != len(want) {
+		t.Fatalf("got chapters %+v, want %+v", v.Chapters, want)
+	}
+	for i := range want {
+		if v.Chapters[i] != want[i] {
+			t.Errorf("chapter %d: got %+v, want %+v", i, v.Chapters[i], want[i])
+		}
+	}
+
+	// the markers are used over the description
+	v = Video{}
+	if err := initVideoData([]byte(`{`+details(`0:00 a\n1:00 b\n2:00 c`)+`, `+markers+`}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Chapters) != 2 || v.Chapters[0].Title != "Intro" {
+		t.Errorf("expected the chapter markers, got %+v", v.Chapters)
+	}
+	// and the description without markers
+	v = Video{}
+	if err := initVideoData([]byte(`{`+details(`0:00 a\n1:00 b\n2:00 c`)+`}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Chapters) != 3 || v.Chapters[0].Title != "a" {
+		t.Errorf("expected the chapters from the description, got %+v", v.Chapters)
+	}
+}
@@ -0,0 +1,200 @@
+package youtube
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Client makes the requests to youtube. Videos, streams and playlists keep
+// a reference to the client that found them and use it to download.
+type Client struct {
+	http *http.Client
+	// limiter is shared by every download
+	limiter      *rateLimiter
+	downloadRate int64
+	log          Logger
+	dumpDir      string
+	dumps        int32
+}
+
+// Logger receives debug messages about the requests that a client makes.
+type Logger interface {
+	Debugf(format string, a ...interface{})
+}
+
+// ClientConfig holds the network settings used to create a Client.
+type ClientConfig struct {
+	// Proxy is the url of an http, https or socks5 proxy. The proxy given
+	// by the environment is used when Proxy is empty.
+	Proxy string
+	// SourceAddress is the local ip address that connections are made from.
+	SourceAddress string
+	// IPVersion forces connections over IPv4 or IPv6 when set to 4 or 6.
+	IPVersion int
+	// DNS is the address of the DNS server used to resolve hosts, for
+	// example "1.1.1.1:53". The system resolver is used when DNS is empty.
+	DNS string
+	// TLS is the tls configuration used for https connections.
+	TLS *tls.Config
+	// UserAgent replaces the default user agent.
+	UserAgent string
+	// RateLimit is the most bytes per second that all downloads made with
+	// the client can use together. Zero means no limit.
+	RateLimit int64
+	// DownloadRateLimit is the most bytes per second that a single
+	// download can use. Zero means no limit.
+	DownloadRateLimit int64
+	// Jar holds the cookies sent with every request. Cookies from a
+	// signed in account give access to the videos the account can see,
+	// see LoadCookies.
+	Jar http.CookieJar
+	// Logger is sent the url, status and time of every request.
+	Logger Logger
+	// DumpPages is a directory where the raw pages and api responses
+	// from youtube are saved. Video data is not saved.
+	DumpPages string
+}
+
+// DefaultClient is the client used by the package level functions.
+var DefaultClient = &Client{
+	http: &http.Client{
+		Transport: &userAgentTransport{
+			agent: agent,
+			inner: http.DefaultTransport,
+		},
+	},
+}
+
+// NewClient creates a client with the given network settings. A nil config
+// is the same as the default client.
+func NewClient(conf *ClientConfig) (*Client, error) {
+	if conf == nil {
+		conf = &ClientConfig{}
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if conf.Proxy != "" {
+		u, err := url.Parse(conf.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+
+	dialer := &net.Dialer{}
+	if conf.SourceAddress != "" {
+		ip := net.ParseIP(conf.SourceAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source address %q", conf.SourceAddress)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	if conf.DNS != "" {
+		server := conf.DNS
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	var network string
+	switch conf.IPVersion {
+	case 0:
+	case 4:
+		network = "tcp4"
+	case 6:
+		network = "tcp6"
+	default:
+		return nil, fmt.Errorf("invalid ip version %d", conf.IPVersion)
+	}
+	tr.DialContext = func(ctx context.Context, netw, addr string) (net.Conn, error) {
+		if network != "" {
+			netw = network
+		}
+		return dialer.DialContext(ctx, netw, addr)
+	}
+	if conf.TLS != nil {
+		tr.TLSClientConfig = conf.TLS.Clone()
+	}
+
+	ua := agent
+	if conf.UserAgent != "" {
+		ua = conf.UserAgent
+	}
+	c := &Client{
+		http: &http.Client{
+			Transport: &userAgentTransport{agent: ua, inner: tr},
+			Jar:       conf.Jar,
+		},
+		downloadRate: conf.DownloadRateLimit,
+		log:          conf.Logger,
+		dumpDir:      conf.DumpPages,
+	}
+	if c.dumpDir != "" {
+		if err := os.MkdirAll(c.dumpDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if conf.RateLimit > 0 {
+		c.limiter = newRateLimiter(conf.RateLimit)
+	}
+	return c, nil
+}
+
+// HTTPClient returns the http client used to make requests.
+func (c *Client) HTTPClient() *http.Client {
+	return c.http
+}
+
+// clientOr returns the client or the default client if it is nil.
+func clientOr(c *Client) *Client {
+	if c == nil {
+		return DefaultClient
+	}
+	return c
+}
+
+func (c *Client) get(u string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	c.authorize(req)
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		c.debugf("%v (%v)", err, elapsed)
+		return nil, err
+	}
+	c.debugf("%s %s: %s (%v)", req.Method, req.URL, resp.Status, elapsed)
+	if c.dumpDir != "" {
+		c.dump(resp)
+	}
+	return resp, nil
+}
+
+func (c *Client) debugf(format string, a ...interface{}) {
+	if c.log != nil {
+		c.log.Debugf(format, a...)
+	}
+}
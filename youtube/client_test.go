@@ -0,0 +1,136 @@
+package youtube
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewClient(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// requests sent to a proxy have the full url
+		proxied = r.URL.String()
+		w.Write([]byte(r.Header.Get("User-Agent")))
+	}))
+	defer proxy.Close()
+
+	c, err := NewClient(&ClientConfig{
+		Proxy:         proxy.URL,
+		SourceAddress: "127.0.0.1",
+		IPVersion:     4,
+		UserAgent:     "yt-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	s := Stream{URL: "http://example.invalid/video", client: c}
+	if _, err = s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://example.invalid/video" {
+		t.Errorf("request did not go through the proxy: %q", proxied)
+	}
+	if buf.String() != "yt-test" {
+		t.Errorf("got user agent %q", buf.String())
+	}
+
+	for _, conf := range []*ClientConfig{
+		{Proxy: "ftp://localhost:21"},
+		{SourceAddress: "not an ip"},
+		{IPVersion: 5},
+	} {
+		if _, err = NewClient(conf); err == nil {
+			t.Errorf("expected an error for %+v", conf)
+		}
+	}
+}
+
+func TestClientIPVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// the test server only listens on IPv4
+	c, err := NewClient(&ClientConfig{IPVersion: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := c.get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected IPv6 connection to an IPv4 address to fail")
+	}
+	if c, err = NewClient(&ClientConfig{IPVersion: 4}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+type testLogger struct{ lines []string }
+
+func (tl *testLogger) Debugf(format string, a ...interface{}) {
+	tl.lines = append(tl.lines, fmt.Sprintf(format, a...))
+}
+
+func TestClientDebug(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/watch":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html></html>"))
+		default:
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write([]byte("data"))
+		}
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "yt-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := &testLogger{}
+	c, err := NewClient(&ClientConfig{Logger: log, DumpPages: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/watch?v=abc", "/videoplayback"} {
+		resp, err := c.get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if len(log.lines) != 3 || !strings.Contains(log.lines[0], "/watch?v=abc: 200 OK") {
+		t.Errorf("bad debug output %q", log.lines)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected only the page to be saved, got %d files", len(files))
+	}
+	if name := files[0].Name(); !strings.HasPrefix(name, "001-127.0.0.1") || !strings.HasSuffix(name, "watch.html") {
+		t.Errorf("bad dump file name %q", name)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "<html></html>" {
+		t.Errorf("got %q in dump file", b)
+	}
+}
@@ -0,0 +1,119 @@
+package youtube
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadCookies reads a Netscape cookies.txt file into a cookie jar that can
+// be given to a client with ClientConfig.Jar. Browser extensions that
+// export cookies for tools such as youtube-dl use this format.
+func LoadCookies(filename string) (http.CookieJar, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCookies(file)
+}
+
+// ReadCookies reads cookies in the Netscape cookies.txt format into a
+// cookie jar. Expired cookies are skipped.
+func ReadCookies(r io.Reader) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		sc   = bufio.NewScanner(r)
+		line int
+		now  = time.Now()
+	)
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		httpOnly := strings.HasPrefix(text, "#HttpOnly_")
+		if httpOnly {
+			text = text[len("#HttpOnly_"):]
+		}
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("cookies line %d: expected 7 tab separated fields, got %d", line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cookies line %d: bad expiration time %q", line, fields[4])
+		}
+		c := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+			if c.Expires.Before(now) {
+				continue
+			}
+		}
+		host := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			// cookies without a domain are only sent to the exact host
+			c.Domain = host
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: c.Path}, []*http.Cookie{c})
+	}
+	if err = sc.Err(); err != nil {
+		return nil, err
+	}
+	return jar, nil
+}
+
+const youtubeOrigin = "https://www.youtube.com"
+
+// authorize adds the SAPISIDHASH authorization header that youtube
+// requires with the cookies of a signed in account.
+func (c *Client) authorize(req *http.Request) {
+	if c.http.Jar == nil || !youtubeHosts[strings.ToLower(req.URL.Hostname())] {
+		return
+	}
+	u, _ := url.Parse(youtubeOrigin)
+	var sapisid string
+	for _, cookie := range c.http.Jar.Cookies(u) {
+		if cookie.Name == "SAPISID" || (sapisid == "" && cookie.Name == "__Secure-3PAPISID") {
+			sapisid = cookie.Value
+		}
+	}
+	if sapisid == "" {
+		return
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Authorization", sapisidHash(sapisid, youtubeOrigin, time.Now()))
+	req.Header.Set("X-Origin", youtubeOrigin)
+	req.Header.Set("X-Goog-AuthUser", "0")
+}
+
+func sapisidHash(sapisid, origin string, now time.Time) string {
+	ts := now.Unix()
+	hash := sha1.Sum([]byte(fmt.Sprintf("%d %s %s", ts, sapisid, origin)))
+	return fmt.Sprintf("SAPISIDHASH %d_%x", ts, hash)
+}
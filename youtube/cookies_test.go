@@ -0,0 +1,84 @@
+package youtube
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const cookiesTxt = `# Netscape HTTP Cookie File
+# This is a generated file!  Do not edit.
+
+.youtube.com	TRUE	/	TRUE	4102444800	SAPISID	abc123/def
+#HttpOnly_.youtube.com	TRUE	/	TRUE	4102444800	SID	session
+.youtube.com	TRUE	/	FALSE	1000	OLD	expired
+127.0.0.1	FALSE	/	FALSE	0	local	value
+`
+
+func TestReadCookies(t *testing.T) {
+	jar, err := ReadCookies(strings.NewReader(cookiesTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://www.youtube.com/watch")
+	got := map[string]string{}
+	for _, c := range jar.Cookies(u) {
+		got[c.Name] = c.Value
+	}
+	if got["SAPISID"] != "abc123/def" || got["SID"] != "session" {
+		t.Errorf("wrong youtube cookies: %v", got)
+	}
+	if _, ok := got["OLD"]; ok {
+		t.Error("expired cookies should be skipped")
+	}
+
+	if _, err = ReadCookies(strings.NewReader("youtube.com\tTRUE\t/\n")); err == nil {
+		t.Error("expected an error for a short line")
+	}
+}
+
+func TestCookieRequests(t *testing.T) {
+	var cookie string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie = r.Header.Get("Cookie")
+	}))
+	defer srv.Close()
+	jar, err := ReadCookies(strings.NewReader(cookiesTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(&ClientConfig{Jar: jar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if cookie != "local=value" {
+		t.Errorf("got cookie header %q", cookie)
+	}
+
+	req, _ := http.NewRequest("GET", "https://www.youtube.com/youtubei/v1/player", nil)
+	c.authorize(req)
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "SAPISIDHASH ") {
+		t.Errorf("missing authorization header: %v", req.Header)
+	}
+	req, _ = http.NewRequest("GET", "https://example.com/", nil)
+	c.authorize(req)
+	if req.Header.Get("Authorization") != "" {
+		t.Error("only requests to youtube should be authorized")
+	}
+}
+
+func TestSAPISIDHash(t *testing.T) {
+	got := sapisidHash("abc123/def", "https://www.youtube.com", time.Unix(1600000000, 0))
+	want := "SAPISIDHASH 1600000000_cbc291b74363594d94d736ca945ddece1b6d0d9b"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,382 @@
+package youtube
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DASHStreams downloads the video's DASH manifest and returns the streams
+// that it describes. Streams from a manifest are usually split into
+// segments but Stream.WriteTo and DownloadFromStream handle both kinds.
+func (v *Video) DASHStreams() (Streams, error) {
+	if v.DASHManifestURL == "" {
+		return nil, errors.New("video has no DASH manifest")
+	}
+	base, err := url.Parse(v.DASHManifestURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := clientOr(v.client).get(v.DASHManifestURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get DASH manifest: %s", resp.Status)
+	}
+	return parseMPD(resp.Body, base)
+}
+
+// AddDASHStreams adds the streams from the DASH manifest whose itags are
+// not already in the adaptive streams. A manifest stream replaces an
+// adaptive stream with the same itag when that one has no url that can be
+// downloaded directly. The manifest is only requested by the first call
+// so it should be called once the streams from the player are not enough.
+func (v *Video) AddDASHStreams() error {
+	if v.DASHManifestURL == "" || !v.Playable() || v.dashAdded {
+		return nil
+	}
+	streams, err := v.DASHStreams()
+	if err != nil {
+		clientOr(v.client).debugf("could not add DASH streams for %s: %v", v.ID, err)
+		return err
+	}
+	v.dashAdded = true
+	vs, as := sortStreams(streams)
+	for i := range vs {
+		vs[i].client = v.client
+	}
+	for i := range as {
+		as[i].client = v.client
+	}
+	v.VideoStreams = mergeStreams(v.VideoStreams, vs)
+	v.AudioStreams = mergeStreams(v.AudioStreams, as)
+	return nil
+}
+
+// mergeStreams adds the streams from extra to streams, keeping the
+// streams that can already be downloaded.
+func mergeStreams(streams, extra []Stream) []Stream {
+	index := make(map[int]int, len(streams))
+	for i, s := range streams {
+		index[s.ITag] = i
+	}
+	for _, s := range extra {
+		i, ok := index[s.ITag]
+		if !ok {
+			index[s.ITag] = len(streams)
+			streams = append(streams, s)
+		} else if !hasDirectURL(streams[i]) {
+			streams[i] = s
+		}
+	}
+	return streams
+}
+
+func hasDirectURL(s Stream) bool {
+	return s.URL != "" || s.Segmented()
+}
+
+type mpd struct {
+	Type     string      `xml:"type,attr"`
+	Duration string      `xml:"mediaPresentationDuration,attr"`
+	BaseURL  string      `xml:"BaseURL"`
+	Periods  []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	Duration       string             `xml:"duration,attr"`
+	BaseURL        string             `xml:"BaseURL"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	MimeType        string              `xml:"mimeType,attr"`
+	Codecs          string              `xml:"codecs,attr"`
+	BaseURL         string              `xml:"BaseURL"`
+	SegmentList     *mpdSegmentList     `xml:"SegmentList"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	Representations []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	ID              string              `xml:"id,attr"`
+	MimeType        string              `xml:"mimeType,attr"`
+	Codecs          string              `xml:"codecs,attr"`
+	Bandwidth       int                 `xml:"bandwidth,attr"`
+	Width           int                 `xml:"width,attr"`
+	Height          int                 `xml:"height,attr"`
+	FrameRate       string              `xml:"frameRate,attr"`
+	BaseURL         string              `xml:"BaseURL"`
+	SegmentList     *mpdSegmentList     `xml:"SegmentList"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+}
+
+type mpdSegmentList struct {
+	Initialization struct {
+		SourceURL string `xml:"sourceURL,attr"`
+	} `xml:"Initialization"`
+	SegmentURLs []struct {
+		Media string `xml:"media,attr"`
+	} `xml:"SegmentURL"`
+}
+
+type mpdSegmentTemplate struct {
+	Media          string `xml:"media,attr"`
+	Initialization string `xml:"initialization,attr"`
+	StartNumber    *int   `xml:"startNumber,attr"`
+	Timescale      int    `xml:"timescale,attr"`
+	Duration       int64  `xml:"duration,attr"`
+	Timeline       []struct {
+		T *int64 `xml:"t,attr"`
+		D int64  `xml:"d,attr"`
+		R int    `xml:"r,attr"`
+	} `xml:"SegmentTimeline>S"`
+}
+
+// parseMPD parses a DASH manifest into a list of streams.
+func parseMPD(r io.Reader, manifest *url.URL) ([]Stream, error) {
+	var m mpd
+	if err := xml.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	base, err := resolve(manifest, m.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	var streams []Stream
+	for _, period := range m.Periods {
+		periodBase, err := resolve(base, period.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		dur := m.Duration
+		if period.Duration != "" {
+			dur = period.Duration
+		}
+		length, _ := parseISODuration(dur)
+		for _, set := range period.AdaptationSets {
+			setBase, err := resolve(periodBase, set.BaseURL)
+			if err != nil {
+				return nil, err
+			}
+			for _, rep := range set.Representations {
+				s, err := representationStream(set, rep, setBase, length)
+				if err != nil {
+					return nil, err
+				}
+				streams = append(streams, s)
+			}
+		}
+	}
+	return streams, nil
+}
+
+func representationStream(set mpdAdaptationSet, rep mpdRepresentation, base *url.URL, length time.Duration) (Stream, error) {
+	base, err := resolve(base, rep.BaseURL)
+	if err != nil {
+		return Stream{}, err
+	}
+	mimetype, codecs := set.MimeType, set.Codecs
+	if rep.MimeType != "" {
+		mimetype = rep.MimeType
+	}
+	if rep.Codecs != "" {
+		codecs = rep.Codecs
+	}
+	s := Stream{
+		MimeType: MimeType{ContentType: mimetype, Codecs: splitCodecs(codecs)},
+		Width:    rep.Width,
+		Height:   rep.Height,
+		Bitrate:  rep.Bandwidth,
+	}
+	s.ITag, _ = strconv.Atoi(rep.ID)
+	if rep.FrameRate != "" {
+		s.FPS = parseFrameRate(rep.FrameRate)
+	}
+	if rep.Height > 0 {
+		s.QualityLabel = fmt.Sprintf("%dp", rep.Height)
+	}
+
+	list, tmpl := rep.SegmentList, rep.SegmentTemplate
+	if list == nil && tmpl == nil {
+		list, tmpl = set.SegmentList, set.SegmentTemplate
+	}
+	switch {
+	case list != nil:
+		if src := list.Initialization.SourceURL; src != "" {
+			if s.Initialization, err = resolveString(base, src); err != nil {
+				return s, err
+			}
+		}
+		for _, seg := range list.SegmentURLs {
+			u, err := resolveString(base, seg.Media)
+			if err != nil {
+				return s, err
+			}
+			s.Segments = append(s.Segments, u)
+		}
+	case tmpl != nil:
+		if err = tmpl.expand(&s, rep, base, length); err != nil {
+			return s, err
+		}
+	default:
+		// the whole representation is a single file
+		s.URL = base.String()
+	}
+	return s, nil
+}
+
+func (t *mpdSegmentTemplate) expand(s *Stream, rep mpdRepresentation, base *url.URL, length time.Duration) (err error) {
+	number := 1
+	if t.StartNumber != nil {
+		number = *t.StartNumber
+	}
+	timescale := int64(t.Timescale)
+	if timescale == 0 {
+		timescale = 1
+	}
+	if t.Initialization != "" {
+		init := fillTemplate(t.Initialization, rep, 0, 0)
+		if s.Initialization, err = resolveString(base, init); err != nil {
+			return err
+		}
+	}
+	add := func(number int, time int64) error {
+		u, err := resolveString(base, fillTemplate(t.Media, rep, number, time))
+		if err != nil {
+			return err
+		}
+		s.Segments = append(s.Segments, u)
+		return nil
+	}
+
+	if len(t.Timeline) > 0 {
+		var start int64
+		for _, seg := range t.Timeline {
+			if seg.T != nil {
+				start = *seg.T
+			}
+			for i := 0; i <= seg.R; i++ {
+				if err = add(number, start); err != nil {
+					return err
+				}
+				number++
+				start += seg.D
+			}
+		}
+		return nil
+	}
+	if t.Duration == 0 || length == 0 {
+		return errors.New("segment template has no timeline or duration")
+	}
+	segDuration := float64(t.Duration) / float64(timescale)
+	count := int(math.Ceil(length.Seconds() / segDuration))
+	for i := 0; i < count; i++ {
+		if err = add(number+i, int64(i)*t.Duration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var templateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Time|Bandwidth)(%0\d+d)?\$`)
+
+// fillTemplate replaces the identifiers in a segment template.
+func fillTemplate(tmpl string, rep mpdRepresentation, number int, time int64) string {
+	s := templateIdentifier.ReplaceAllStringFunc(tmpl, func(match string) string {
+		m := templateIdentifier.FindStringSubmatch(match)
+		format := "%d"
+		if m[2] != "" {
+			format = m[2]
+		}
+		switch m[1] {
+		case "RepresentationID":
+			return rep.ID
+		case "Number":
+			return fmt.Sprintf(format, number)
+		case "Time":
+			return fmt.Sprintf(format, time)
+		case "Bandwidth":
+			return fmt.Sprintf(format, rep.Bandwidth)
+		}
+		return match
+	})
+	return strings.Replace(s, "$$", "$", -1)
+}
+
+var isoDurationRegex = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses the subset of ISO 8601 durations used in
+// DASH manifests such as "PT1H2M3.5S".
+func parseISODuration(s string) (time.Duration, error) {
+	m := isoDurationRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d, nil
+}
+
+func parseFrameRate(s string) int {
+	parts := strings.SplitN(s, "/", 2)
+	n, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0
+	}
+	if len(parts) == 2 {
+		d, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || d == 0 {
+			return 0
+		}
+		n /= d
+	}
+	return int(math.Round(n))
+}
+
+func splitCodecs(codecs string) []string {
+	if codecs == "" {
+		return nil
+	}
+	parts := strings.Split(codecs, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func resolve(base *url.URL, ref string) (*url.URL, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return base, nil
+	}
+	return base.Parse(ref)
+}
+
+func resolveString(base *url.URL, ref string) (string, error) {
+	u, err := resolve(base, ref)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,213 @@
+package youtube
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMPD = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:DASH:schema:MPD:2011" type="static" mediaPresentationDuration="PT9.5S">
+  <Period>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="140" codecs="mp4a.40.2" bandwidth="144000">
+        <BaseURL>https://cdn.example.com/audio/</BaseURL>
+        <SegmentList>
+          <Initialization sourceURL="sq/0"/>
+          <SegmentURL media="sq/1"/>
+          <SegmentURL media="sq/2"/>
+        </SegmentList>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate media="$RepresentationID$/seg-$Number%03d$.m4s" initialization="$RepresentationID$/init.mp4" timescale="1000" duration="4000" startNumber="1"/>
+      <Representation id="137" codecs="avc1.640028" bandwidth="4000000" width="1920" height="1080" frameRate="30000/1001"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="video/webm" codecs="vp9">
+      <Representation id="248" bandwidth="2000000" width="1920" height="1080">
+        <SegmentTemplate media="t/$Time$" timescale="1">
+          <SegmentTimeline>
+            <S t="10" d="5" r="1"/>
+            <S d="2"/>
+          </SegmentTimeline>
+        </SegmentTemplate>
+      </Representation>
+      <Representation id="247" bandwidth="1000000" width="1280" height="720">
+        <BaseURL>single.webm</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestParseMPD(t *testing.T) {
+	base, _ := url.Parse("https://manifest.example.com/api/manifest/dash/id/1")
+	streams, err := parseMPD(strings.NewReader(testMPD), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 4 {
+		t.Fatalf("wrong number of streams: got %d, want 4", len(streams))
+	}
+
+	audio := streams[0]
+	if !audio.IsAudioStream() || audio.IsVideoStream() || audio.ITag != 140 || audio.Bitrate != 144000 {
+		t.Errorf("wrong audio stream: %+v", audio)
+	}
+	if audio.Initialization != "https://cdn.example.com/audio/sq/0" {
+		t.Errorf("wrong initialization url: %s", audio.Initialization)
+	}
+	if len(audio.Segments) != 2 || audio.Segments[1] != "https://cdn.example.com/audio/sq/2" {
+		t.Errorf("wrong segments: %v", audio.Segments)
+	}
+
+	video := streams[1]
+	if !video.IsVideoStream() || video.Height != 1080 || video.FPS != 30 || video.QualityLabel != "1080p" {
+		t.Errorf("wrong video stream: %+v", video)
+	}
+	if video.Initialization != "https://manifest.example.com/api/manifest/dash/id/137/init.mp4" {
+		t.Errorf("wrong template initialization: %s", video.Initialization)
+	}
+	// 9.5 seconds in 4 second segments
+	if len(video.Segments) != 3 || !strings.HasSuffix(video.Segments[2], "/137/seg-003.m4s") {
+		t.Errorf("wrong template segments: %v", video.Segments)
+	}
+
+	timeline := streams[2]
+	want := []string{"t/10", "t/15", "t/20"}
+	if len(timeline.Segments) != len(want) {
+		t.Fatalf("wrong timeline segments: %v", timeline.Segments)
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(timeline.Segments[i], w) {
+			t.Errorf("segment %d: got %s, want suffix %s", i, timeline.Segments[i], w)
+		}
+	}
+	if timeline.MimeType.ContentType != "video/webm" || timeline.MimeType.Codecs[0] != "vp9" {
+		t.Errorf("representation should inherit the adaptation set's mime type: %+v", timeline.MimeType)
+	}
+
+	single := streams[3]
+	if single.Segmented() || single.URL != "https://manifest.example.com/api/manifest/dash/id/single.webm" {
+		t.Errorf("wrong single file stream: %+v", single)
+	}
+}
+
+func TestAddDASHStreams(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, testMPD)
+	}))
+	defer srv.Close()
+	log := &testLogger{}
+	c, err := NewClient(&ClientConfig{Logger: log})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &Video{
+		client:          c,
+		DASHManifestURL: srv.URL + "/manifest",
+		VideoStreams: []Stream{
+			{ITag: 137, URL: "https://direct.example.com/137"},
+			{ITag: 248}, // needs a signature that could not be deciphered
+		},
+		AudioStreams: []Stream{{ITag: 251, URL: "https://direct.example.com/251"}},
+	}
+	if err = v.AddDASHStreams(); err != nil {
+		t.Fatal(err)
+	}
+	itags := func(streams []Stream) (tags []int) {
+		for _, s := range streams {
+			tags = append(tags, s.ITag)
+		}
+		return tags
+	}
+	if got := fmt.Sprint(itags(v.VideoStreams)); got != "[137 248 247]" {
+		t.Errorf("wrong video streams: %s", got)
+	}
+	if got := fmt.Sprint(itags(v.AudioStreams)); got != "[251 140]" {
+		t.Errorf("wrong audio streams: %s", got)
+	}
+	if v.VideoStreams[0].URL != "https://direct.example.com/137" {
+		t.Errorf("a stream with a direct url should be kept: %+v", v.VideoStreams[0])
+	}
+	if !v.VideoStreams[1].Segmented() {
+		t.Errorf("a stream without a url should be replaced by the manifest's: %+v", v.VideoStreams[1])
+	}
+	if err = v.AddDASHStreams(); err != nil || requests != 1 || len(v.VideoStreams) != 3 {
+		t.Errorf("the manifest should only be added once: %d requests, %v", requests, err)
+	}
+
+	v = &Video{client: c, DASHManifestURL: srv.URL + "/missing"}
+	if err = v.AddDASHStreams(); err == nil {
+		t.Error("expected an error for a missing manifest")
+	}
+	if len(v.VideoStreams) != 0 || len(v.AudioStreams) != 0 {
+		t.Error("no streams should be added from a missing manifest")
+	}
+	last := log.lines[len(log.lines)-1]
+	if !strings.Contains(last, "could not add DASH streams") || !strings.Contains(last, "404") {
+		t.Errorf("the manifest error should be logged, got %q", log.lines)
+	}
+}
+
+func TestParseISODuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT9.5S":     9500 * time.Millisecond,
+		"PT1H2M3S":   time.Hour + 2*time.Minute + 3*time.Second,
+		"P1DT1M":     24*time.Hour + time.Minute,
+		"PT0.000S":   0,
+		"PT210.000S": 210 * time.Second,
+	}
+	for s, want := range tests {
+		d, err := parseISODuration(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+		if d != want {
+			t.Errorf("%s: got %v, want %v", s, d, want)
+		}
+	}
+	if _, err := parseISODuration("1 hour"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestSegmentedWriteTo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	s := Stream{
+		Initialization: srv.URL + "/init",
+		Segments:       []string{srv.URL + "/1", srv.URL + "/2"},
+	}
+	var buf bytes.Buffer
+	n, err := s.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "/init/1/2" || n != int64(buf.Len()) {
+		t.Errorf("wrong data written: %q (%d bytes)", buf.String(), n)
+	}
+
+	s.Segments = append(s.Segments, srv.URL+"/missing")
+	if _, err = s.WriteTo(&buf); err == nil {
+		t.Error("expected an error for a missing segment")
+	}
+}
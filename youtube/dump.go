@@ -0,0 +1,74 @@
+package youtube
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// dump saves the body of a response to the client's dump directory as it
+// is read. Media responses are skipped since they are only useful for
+// debugging when they are a few bytes long.
+func (c *Client) dump(resp *http.Response) {
+	ext := dumpExt(resp.Header.Get("Content-Type"))
+	if ext == "" {
+		return
+	}
+	n := atomic.AddInt32(&c.dumps, 1)
+	name := fmt.Sprintf("%03d-%s%s", n, dumpName(resp.Request.URL.Host+resp.Request.URL.Path), ext)
+	file, err := os.Create(filepath.Join(c.dumpDir, name))
+	if err != nil {
+		c.debugf("could not dump page: %v", err)
+		return
+	}
+	c.debugf("saving response to %s", file.Name())
+	resp.Body = &dumpReader{Reader: io.TeeReader(resp.Body, file), body: resp.Body, file: file}
+}
+
+type dumpReader struct {
+	io.Reader
+	body io.Closer
+	file *os.File
+}
+
+func (dr *dumpReader) Close() error {
+	dr.file.Close()
+	return dr.body.Close()
+}
+
+func dumpExt(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ".txt"
+	}
+	switch {
+	case strings.HasPrefix(t, "video/"), strings.HasPrefix(t, "audio/"),
+		strings.HasPrefix(t, "image/"), t == "application/octet-stream":
+		return ""
+	case strings.HasSuffix(t, "json"):
+		return ".json"
+	case strings.HasSuffix(t, "html"):
+		return ".html"
+	case strings.HasSuffix(t, "xml"):
+		return ".xml"
+	case strings.Contains(t, "mpegurl"):
+		return ".m3u8"
+	}
+	return ".txt"
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func dumpName(s string) string {
+	s = strings.Trim(unsafeChars.ReplaceAllString(s, "_"), "_")
+	if len(s) > 80 {
+		s = s[:80]
+	}
+	return s
+}
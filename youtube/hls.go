@@ -0,0 +1,350 @@
+package youtube
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LiveOptions controls how a live stream is recorded.
+type LiveOptions struct {
+	// Duration is the longest amount of time that will be recorded. A
+	// duration of zero will record until the stream ends.
+	Duration time.Duration
+	// FromStart will record from the oldest segment that is still in the
+	// stream's playlist when the stream has DVR enabled. That is usually
+	// the last few hours of the stream, not always its beginning. Otherwise
+	// recording starts from the current position of the stream.
+	FromStart bool
+}
+
+// DownloadLive records a live stream to a file. The file is written as
+// an MPEG transport stream so '.ts' is the suggested file extension. A nil
+// opts will record from now until the stream ends. Like
+// DownloadFromStream, the recording is written to a ".part" file which is
+// only renamed to the file name once the recording has stopped without an
+// error.
+func (v *Video) DownloadLive(fname string, opts *LiveOptions) error {
+	part := fname + ".part"
+	file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	err = v.RecordLive(file, opts)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+	return os.Rename(part, fname)
+}
+
+// RecordLive records a live stream to an io.Writer by polling the video's
+// HLS playlist for new segments until the stream ends or the duration
+// limit is reached.
+func (v *Video) RecordLive(w io.Writer, opts *LiveOptions) error {
+	if v.HLSManifestURL == "" {
+		return errors.New("video has no live stream manifest")
+	}
+	if opts == nil {
+		opts = &LiveOptions{}
+	}
+	c := clientOr(v.client)
+	media, err := c.bestVariant(v.HLSManifestURL)
+	if err != nil {
+		return err
+	}
+	rec := recorder{
+		client:    c,
+		w:         c.limit(w),
+		limit:     opts.Duration,
+		fromStart: opts.FromStart && v.IsLiveDVR,
+		last:      -1,
+	}
+	return rec.record(media)
+}
+
+// livePollTimeout is how long to wait for new segments before
+// deciding that a stream without an end marker has ended.
+var livePollTimeout = 30 * time.Second
+
+type recorder struct {
+	client    *Client
+	w         io.Writer
+	limit     time.Duration
+	recorded  time.Duration
+	fromStart bool
+	// sequence number of the last segment written
+	last int
+}
+
+func (r *recorder) record(playlist string) error {
+	lastNew := time.Now()
+	for {
+		p, err := r.client.fetchHLS(playlist)
+		if err != nil {
+			return err
+		}
+		if len(p.variants) > 0 {
+			return errors.New("expected a media playlist, got a master playlist")
+		}
+		segments := p.segments
+		if r.last < 0 && !r.fromStart && !p.ended && len(segments) > 1 {
+			// start from the live edge
+			segments = segments[len(segments)-1:]
+		}
+		for _, seg := range segments {
+			if seg.sequence <= r.last {
+				continue
+			}
+			if err = r.writeSegment(seg); err != nil {
+				return err
+			}
+			lastNew = time.Now()
+			if r.limit > 0 && r.recorded >= r.limit {
+				return nil
+			}
+		}
+		if p.ended {
+			return nil
+		}
+		if time.Since(lastNew) > livePollTimeout {
+			return nil
+		}
+		time.Sleep(p.pollInterval())
+	}
+}
+
+func (r *recorder) writeSegment(seg hlsSegment) error {
+	resp, err := r.client.get(seg.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get segment %d: %s", seg.sequence, resp.Status)
+	}
+	if _, err = io.Copy(r.w, resp.Body); err != nil {
+		return err
+	}
+	r.last = seg.sequence
+	r.recorded += seg.duration
+	return nil
+}
+
+// WaitForLive polls an upcoming live stream or premiere every interval
+// until it starts and returns the playable video.
+func WaitForLive(id string, interval time.Duration) (*Video, error) {
+	return DefaultClient.WaitForLive(id, interval)
+}
+
+// WaitForLive polls an upcoming live stream or premiere every interval
+// until it starts and returns the playable video.
+func (c *Client) WaitForLive(id string, interval time.Duration) (*Video, error) {
+	if interval < minLiveInterval {
+		interval = minLiveInterval
+	}
+	for {
+		v, err := c.LookupVideo(id)
+		if err != nil {
+			return nil, err
+		}
+		if v.Playable() && !v.IsUpcoming {
+			return v, nil
+		}
+		if !v.IsUpcoming {
+			return nil, v.PlayabilityError()
+		}
+		time.Sleep(liveWait(interval, time.Until(v.ScheduledStart)))
+	}
+}
+
+// minLiveInterval is the shortest time between checks of an upcoming
+// stream.
+const minLiveInterval = time.Second
+
+// liveWait returns how long to wait before checking an upcoming stream
+// that is scheduled to start after until. The stream is checked at least
+// every interval so that a stream that is rescheduled is noticed and
+// sooner if it is scheduled to start before then.
+func liveWait(interval, until time.Duration) time.Duration {
+	if until > 0 && until < interval {
+		interval = until
+	}
+	if interval < minLiveInterval {
+		return minLiveInterval
+	}
+	return interval
+}
+
+type hlsPlaylist struct {
+	variants       []hlsVariant
+	segments       []hlsSegment
+	targetDuration time.Duration
+	ended          bool
+}
+
+type hlsVariant struct {
+	url       string
+	bandwidth int
+}
+
+type hlsSegment struct {
+	url      string
+	sequence int
+	duration time.Duration
+}
+
+func (p *hlsPlaylist) pollInterval() time.Duration {
+	d := p.targetDuration / 2
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+// bestVariant returns the url of the highest bandwidth media
+// playlist in a master playlist.
+func (c *Client) bestVariant(master string) (string, error) {
+	p, err := c.fetchHLS(master)
+	if err != nil {
+		return "", err
+	}
+	if len(p.variants) == 0 {
+		// already a media playlist
+		return master, nil
+	}
+	best := p.variants[0]
+	for _, v := range p.variants[1:] {
+		if v.bandwidth > best.bandwidth {
+			best = v
+		}
+	}
+	return best.url, nil
+}
+
+func (c *Client) fetchHLS(u string) (*hlsPlaylist, error) {
+	base, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get live playlist: %s", resp.Status)
+	}
+	return parseHLS(resp.Body, base)
+}
+
+func parseHLS(r io.Reader, base *url.URL) (*hlsPlaylist, error) {
+	var (
+		p        = &hlsPlaylist{}
+		sc       = bufio.NewScanner(r)
+		sequence int
+		duration time.Duration
+		variant  *hlsVariant
+		first    = true
+	)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if first {
+			if line != "#EXTM3U" {
+				return nil, errors.New("invalid HLS playlist")
+			}
+			first = false
+			continue
+		}
+		tag, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			tag, value = line[:i], line[i+1:]
+		}
+		switch tag {
+		case "#EXT-X-STREAM-INF":
+			variant = &hlsVariant{}
+			variant.bandwidth, _ = strconv.Atoi(hlsAttributes(value)["BANDWIDTH"])
+		case "#EXT-X-MEDIA-SEQUENCE":
+			sequence, _ = strconv.Atoi(value)
+		case "#EXT-X-TARGETDURATION":
+			n, _ := strconv.ParseFloat(value, 64)
+			p.targetDuration = time.Duration(n * float64(time.Second))
+		case "#EXTINF":
+			if i := strings.IndexByte(value, ','); i >= 0 {
+				value = value[:i]
+			}
+			n, _ := strconv.ParseFloat(value, 64)
+			duration = time.Duration(n * float64(time.Second))
+		case "#EXT-X-ENDLIST":
+			p.ended = true
+		default:
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			ref, err := base.Parse(line)
+			if err != nil {
+				return nil, err
+			}
+			if variant != nil {
+				variant.url = ref.String()
+				p.variants = append(p.variants, *variant)
+				variant = nil
+				continue
+			}
+			p.segments = append(p.segments, hlsSegment{
+				url:      ref.String(),
+				sequence: sequence,
+				duration: duration,
+			})
+			sequence++
+			duration = 0
+		}
+	}
+	if first {
+		return nil, errors.New("empty HLS playlist")
+	}
+	return p, sc.Err()
+}
+
+// hlsAttributes parses an HLS attribute list of the form
+// KEY=value,KEY="quoted, value".
+func hlsAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.IndexByte(s, ','); comma >= 0 {
+			val, s = s[:comma], s[comma:]
+		} else {
+			val, s = s, ""
+		}
+		attrs[key] = val
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
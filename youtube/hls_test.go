@@ -0,0 +1,167 @@
+package youtube
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHLS(t *testing.T) {
+	base, _ := url.Parse("https://example.com/live/master.m3u8")
+	master := `#EXTM3U
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720
+720/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=640000,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360
+https://other.example.com/360/index.m3u8
+`
+	p, err := parseHLS(strings.NewReader(master), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.variants) != 2 {
+		t.Fatalf("wrong number of variants: got %d, want 2", len(p.variants))
+	}
+	if p.variants[0].url != "https://example.com/live/720/index.m3u8" || p.variants[0].bandwidth != 1280000 {
+		t.Errorf("wrong first variant: %+v", p.variants[0])
+	}
+	if p.variants[1].url != "https://other.example.com/360/index.m3u8" {
+		t.Errorf("wrong second variant: %+v", p.variants[1])
+	}
+
+	media := `#EXTM3U
+#EXT-X-TARGETDURATION:5
+#EXT-X-MEDIA-SEQUENCE:42
+#EXTINF:5.005,
+seg42.ts
+#EXTINF:4.5,
+seg43.ts
+#EXT-X-ENDLIST
+`
+	p, err = parseHLS(strings.NewReader(media), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.ended || p.targetDuration != 5*time.Second {
+		t.Errorf("wrong playlist info: ended=%v target=%v", p.ended, p.targetDuration)
+	}
+	if len(p.segments) != 2 {
+		t.Fatalf("wrong number of segments: got %d, want 2", len(p.segments))
+	}
+	if s := p.segments[1]; s.sequence != 43 || s.duration != 4500*time.Millisecond || s.url != "https://example.com/live/seg43.ts" {
+		t.Errorf("wrong segment: %+v", s)
+	}
+
+	if _, err = parseHLS(strings.NewReader("not a playlist"), base); err == nil {
+		t.Error("expected error for invalid playlist")
+	}
+}
+
+func TestRecordLive(t *testing.T) {
+	var ended bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=200\nhigh.m3u8\n")
+	})
+	mux.HandleFunc("/high.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:0\n")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "#EXTINF:2.0,\nseg%d.ts\n", i)
+		}
+		if ended {
+			fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+		}
+	})
+	mux.HandleFunc("/low.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("should have chosen the highest bandwidth variant")
+	})
+	for i := 0; i < 3; i++ {
+		data := fmt.Sprintf("[segment %d]", i)
+		mux.HandleFunc(fmt.Sprintf("/seg%d.ts", i), func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, data)
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	v := &Video{HLSManifestURL: srv.URL + "/master.m3u8"}
+	v.IsLive = true
+
+	// a stream that is still live should start from the live edge
+	var buf bytes.Buffer
+	if err := v.RecordLive(&buf, &LiveOptions{Duration: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[segment 2]" {
+		t.Errorf("expected to record from the live edge, got %q", buf.String())
+	}
+
+	// a finished stream should be recorded in full
+	ended = true
+	buf.Reset()
+	if err := v.RecordLive(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[segment 0][segment 1][segment 2]" {
+		t.Errorf("wrong recording: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := v.RecordLive(&buf, &LiveOptions{Duration: 3 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[segment 0][segment 1]" {
+		t.Errorf("recording should stop at the duration limit: %q", buf.String())
+	}
+
+	dir, err := ioutil.TempDir("", "yt-live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "live.ts")
+	if err = v.DownloadLive(fname, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(fname); err != nil || string(b) != "[segment 0][segment 1][segment 2]" {
+		t.Errorf("wrong recording %q: %v", b, err)
+	}
+	// a recording that fails is not mistaken for a finished one
+	failed := filepath.Join(dir, "failed.ts")
+	broken := &Video{HLSManifestURL: srv.URL + "/missing.m3u8"}
+	broken.IsLive = true
+	if err = broken.DownloadLive(failed, nil); err == nil {
+		t.Fatal("expected an error for a missing playlist")
+	}
+	for _, name := range []string{failed, failed + ".part", fname + ".part"} {
+		if _, err = os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist", name)
+		}
+	}
+}
+
+func TestLiveWait(t *testing.T) {
+	tests := []struct {
+		interval, until, want time.Duration
+	}{
+		{time.Minute, 0, time.Minute},
+		{time.Minute, 10 * time.Hour, time.Minute},
+		{time.Minute, 20 * time.Second, 20 * time.Second},
+		{time.Minute, -time.Hour, time.Minute},
+		{0, 0, minLiveInterval},
+		{time.Minute, time.Millisecond, minLiveInterval},
+	}
+	for _, tt := range tests {
+		if got := liveWait(tt.interval, tt.until); got != tt.want {
+			t.Errorf("liveWait(%v, %v) = %v, want %v", tt.interval, tt.until, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package youtube
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ByteRange is an inclusive range of bytes in a stream.
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// UnmarshalJSON decodes byte ranges from youtube which stores the
+// numbers as strings.
+func (br *ByteRange) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Start json.Number `json:"start"`
+		End   json.Number `json:"end"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	start, err := strconv.ParseInt(string(raw.Start), 10, 64)
+	if err != nil {
+		return err
+	}
+	end, err := strconv.ParseInt(string(raw.End), 10, 64)
+	if err != nil {
+		return err
+	}
+	br.Start, br.End = start, end
+	return nil
+}
+
+// MarshalJSON encodes the byte range in the same format as youtube.
+func (br ByteRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"start": strconv.FormatInt(br.Start, 10),
+		"end":   strconv.FormatInt(br.End, 10),
+	})
+}
+
+func (br ByteRange) header() string {
+	if br.End < 0 {
+		return fmt.Sprintf("bytes=%d-", br.Start)
+	}
+	return fmt.Sprintf("bytes=%d-%d", br.Start, br.End)
+}
+
+// segmentRef is the location of a segment of media in a stream.
+type segmentRef struct {
+	bytes ByteRange
+	time  time.Duration
+	// duration is zero if the segment's duration is unknown
+	duration time.Duration
+}
+
+// ErrNoIndex is returned when a stream does not have an index
+// that can be used to download part of the stream.
+var ErrNoIndex = errors.New("stream has no index")
+
+// DownloadRange downloads only the segments of the stream that are needed
+// to play from start to end. An end of zero will download until the end of
+// the stream. The stream must have an index which adaptive streams usually
+// have. The file will start at the beginning of the segment containing
+// start, so the time of that segment in the original stream is returned
+// so that the file can be trimmed precisely. Like DownloadFromStream, the
+// data is written to a ".part" file which is renamed once it is complete.
+func (s *Stream) DownloadRange(fname string, start, end time.Duration) (time.Duration, error) {
+	if !s.hasIndex() {
+		return 0, ErrNoIndex
+	}
+	part := fname + ".part"
+	file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := s.WriteRange(file, start, end)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(part)
+		return 0, err
+	}
+	return offset, os.Rename(part, fname)
+}
+
+// WriteRange writes the segments of the stream that are needed to play
+// from start to end to w. See DownloadRange. ErrNoIndex is returned
+// before anything is written if the stream does not have a usable index.
+func (s *Stream) WriteRange(w io.Writer, start, end time.Duration) (time.Duration, error) {
+	if !s.hasIndex() {
+		return 0, ErrNoIndex
+	}
+	u, err := s.GetURL()
+	if err != nil {
+		return 0, err
+	}
+	c := clientOr(s.client)
+	header, err := c.fetchRange(u.String(), ByteRange{0, s.IndexRange.End})
+	if err != nil {
+		return 0, err
+	}
+	if int64(len(header)) <= s.IndexRange.End {
+		return 0, errors.New("stream index was cut short")
+	}
+	if err = checkContainer(s.MimeType.ContentType, header); err != nil {
+		return 0, err
+	}
+	refs, err := s.parseIndex(header)
+	if err != nil {
+		return 0, err
+	}
+	first, last := selectSegments(refs, start, end)
+	if first < 0 {
+		return 0, errors.New("time range is outside of the stream")
+	}
+	byteRange := ByteRange{refs[first].bytes.Start, refs[last].bytes.End}
+
+	if _, err = w.Write(header[:s.InitRange.End+1]); err != nil {
+		return 0, err
+	}
+	resp, err := c.getRange(u.String(), byteRange)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(c.limit(w), resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if want := byteRange.End - byteRange.Start + 1; byteRange.End >= 0 && n != want {
+		return 0, &IntegrityError{fmt.Sprintf("got %d of %d bytes", n, want)}
+	}
+	return refs[first].time, nil
+}
+
+// hasIndex returns true if the stream's init and index ranges can be
+// used to find its segments. The ranges come from the player so they are
+// checked before they are used to slice the stream's header.
+func (s *Stream) hasIndex() bool {
+	init, index := s.InitRange, s.IndexRange
+	return init != nil && index != nil &&
+		init.Start >= 0 && init.Start <= init.End &&
+		index.Start >= 0 && index.Start <= index.End &&
+		init.End <= index.End
+}
+
+func (s *Stream) parseIndex(header []byte) ([]segmentRef, error) {
+	if !s.hasIndex() || int64(len(header)) <= s.IndexRange.End {
+		return nil, ErrNoIndex
+	}
+	index := header[s.IndexRange.Start : s.IndexRange.End+1]
+	var refs []segmentRef
+	if bytes.HasPrefix(header, ebmlMagic) {
+		segmentOffset, scale, err := parseWebMHeader(header[:s.InitRange.End+1])
+		if err != nil {
+			return nil, err
+		}
+		refs, err = parseCues(index, segmentOffset, scale)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		refs, err = parseSidx(index, s.IndexRange.End+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(refs) == 0 {
+		return nil, errors.New("stream index has no segments")
+	}
+	return refs, nil
+}
+
+// selectSegments returns the indexes of the first and last segments that
+// cover the time range. Returns -1 if no segments cover the range.
+func selectSegments(refs []segmentRef, start, end time.Duration) (first, last int) {
+	first, last = -1, len(refs)-1
+	for i, ref := range refs {
+		segEnd := ref.time + ref.duration
+		if first < 0 && (segEnd > start || ref.duration == 0) {
+			first = i
+		}
+		if end > 0 && ref.time >= end {
+			last = i - 1
+			break
+		}
+	}
+	if first < 0 || last < first {
+		return -1, -1
+	}
+	return first, last
+}
+
+func (c *Client) fetchRange(url string, br ByteRange) ([]byte, error) {
+	resp, err := c.getRange(url, br)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (c *Client) getRange(url string, br ByteRange) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", br.header())
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not get byte range %s: %s", br.header(), resp.Status)
+	}
+	return resp, nil
+}
+
+// parseSidx parses an mp4 segment index box. The anchor is the offset of
+// the first byte after the box which the segment offsets are relative to.
+func parseSidx(b []byte, anchor int64) ([]segmentRef, error) {
+	if len(b) < 8 || string(b[4:8]) != "sidx" {
+		return nil, errors.New("index is not a sidx box")
+	}
+	r := bytes.NewReader(b[8:])
+	var head struct {
+		Version   uint8
+		Flags     [3]byte
+		RefID     uint32
+		Timescale uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &head); err != nil {
+		return nil, err
+	}
+	if head.Timescale == 0 {
+		return nil, errors.New("sidx has no timescale")
+	}
+	var earliest, offset uint64
+	if head.Version == 0 {
+		var v [2]uint32
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return nil, err
+		}
+		earliest, offset = uint64(v[0]), uint64(v[1])
+	} else {
+		var v [2]uint64
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return nil, err
+		}
+		earliest, offset = v[0], v[1]
+	}
+	var counts struct {
+		Reserved uint16
+		Count    uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &counts); err != nil {
+		return nil, err
+	}
+	var (
+		refs  = make([]segmentRef, counts.Count)
+		pos   = anchor + int64(offset)
+		ticks = earliest
+		scale = time.Duration(head.Timescale)
+	)
+	for i := range refs {
+		var entry struct {
+			Size     uint32
+			Duration uint32
+			SAP      uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &entry); err != nil {
+			return nil, err
+		}
+		size := int64(entry.Size & 0x7fffffff)
+		refs[i] = segmentRef{
+			bytes:    ByteRange{pos, pos + size - 1},
+			time:     time.Duration(ticks) * time.Second / scale,
+			duration: time.Duration(entry.Duration) * time.Second / scale,
+		}
+		pos += size
+		ticks += uint64(entry.Duration)
+	}
+	return refs, nil
+}
+
+var ebmlMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// webm element ids
+const (
+	segmentID           = 0x18538067
+	infoID              = 0x1549a966
+	timecodeScaleID     = 0x2ad7b1
+	cuesID              = 0x1c53bb6b
+	cuePointID          = 0xbb
+	cueTimeID           = 0xb3
+	cueTrackPositionsID = 0xb7
+	cueClusterPosID     = 0xf1
+	clusterID           = 0x1f43b675
+)
+
+type ebmlElement struct {
+	id   uint64
+	data []byte
+	// offset of the element's data
+	offset int64
+}
+
+// readElement reads the ebml element at the start of b. Elements with
+// an unknown or oversized length are given the rest of b.
+func readElement(b []byte, offset int64) (ebmlElement, int, error) {
+	id, n, err := readVint(b, true)
+	if err != nil {
+		return ebmlElement{}, 0, err
+	}
+	size, m, err := readVint(b[n:], false)
+	if err != nil {
+		return ebmlElement{}, 0, err
+	}
+	head := n + m
+	rest := uint64(len(b) - head)
+	if size > rest {
+		size = rest
+	}
+	return ebmlElement{
+		id:     id,
+		data:   b[head : head+int(size)],
+		offset: offset + int64(head),
+	}, head + int(size), nil
+}
+
+// readVint reads an ebml variable length integer. Element ids keep the
+// length marker bits and sizes do not.
+func readVint(b []byte, keepMarker bool) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && b[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || len(b) < length {
+		return 0, 0, errors.New("invalid ebml integer")
+	}
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= uint64(0xff >> uint(length))
+	}
+	for _, c := range b[1:length] {
+		v = v<<8 | uint64(c)
+	}
+	return v, length, nil
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// parseWebMHeader finds the offset of the segment's data and the timecode
+// scale in nanoseconds from the start of a webm file.
+func parseWebMHeader(b []byte) (segmentOffset int64, scale uint64, err error) {
+	scale = 1000000
+	var pos int
+	for pos < len(b) {
+		el, n, err := readElement(b[pos:], int64(pos))
+		if err != nil {
+			return 0, 0, err
+		}
+		if el.id == segmentID {
+			segmentOffset = el.offset
+			break
+		}
+		pos += n
+	}
+	if segmentOffset == 0 {
+		return 0, 0, errors.New("could not find webm segment")
+	}
+	children := b[segmentOffset:]
+	for pos = 0; pos < len(children); {
+		el, n, err := readElement(children[pos:], 0)
+		if err != nil || el.id == clusterID || el.id == cuesID {
+			break
+		}
+		if el.id == infoID {
+			for i := 0; i < len(el.data); {
+				child, m, err := readElement(el.data[i:], 0)
+				if err != nil {
+					break
+				}
+				if child.id == timecodeScaleID {
+					scale = readUint(child.data)
+				}
+				i += m
+			}
+			break
+		}
+		pos += n
+	}
+	return segmentOffset, scale, nil
+}
+
+// parseCues parses a webm Cues element into segment references. The
+// cluster positions in the cues are relative to the segment offset.
+func parseCues(b []byte, segmentOffset int64, scale uint64) ([]segmentRef, error) {
+	cues, _, err := readElement(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if cues.id != cuesID {
+		return nil, errors.New("index is not a webm cues element")
+	}
+	var refs []segmentRef
+	for pos := 0; pos < len(cues.data); {
+		point, n, err := readElement(cues.data[pos:], 0)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		if point.id != cuePointID {
+			continue
+		}
+		var (
+			ref     segmentRef
+			haveCue bool
+		)
+		for i := 0; i < len(point.data); {
+			el, m, err := readElement(point.data[i:], 0)
+			if err != nil {
+				return nil, err
+			}
+			i += m
+			switch el.id {
+			case cueTimeID:
+				ref.time = time.Duration(readUint(el.data) * scale)
+			case cueTrackPositionsID:
+				for j := 0; j < len(el.data); {
+					pos, k, err := readElement(el.data[j:], 0)
+					if err != nil {
+						return nil, err
+					}
+					j += k
+					if pos.id == cueClusterPosID {
+						ref.bytes.Start = segmentOffset + int64(readUint(pos.data))
+						haveCue = true
+					}
+				}
+			}
+		}
+		if haveCue {
+			refs = append(refs, ref)
+		}
+	}
+	for i := range refs {
+		if i+1 < len(refs) {
+			refs[i].bytes.End = refs[i+1].bytes.Start - 1
+			refs[i].duration = refs[i+1].time - refs[i].time
+		} else {
+			// the last cluster goes to the end of the stream
+			refs[i].bytes.End = -1
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,200 @@
+package youtube
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sidxBox(timescale uint32, sizes []uint32, duration uint32) []byte {
+	var body bytes.Buffer
+	binary.Write(&body, binary.BigEndian, struct {
+		Version   uint8
+		Flags     [3]byte
+		RefID     uint32
+		Timescale uint32
+		Earliest  uint32
+		Offset    uint32
+		Reserved  uint16
+		Count     uint16
+	}{RefID: 1, Timescale: timescale, Count: uint16(len(sizes))})
+	for _, size := range sizes {
+		binary.Write(&body, binary.BigEndian, [3]uint32{size, duration, 0x90000000})
+	}
+	var box bytes.Buffer
+	binary.Write(&box, binary.BigEndian, uint32(body.Len()+8))
+	box.WriteString("sidx")
+	box.Write(body.Bytes())
+	return box.Bytes()
+}
+
+func TestParseSidx(t *testing.T) {
+	refs, err := parseSidx(sidxBox(1000, []uint32{100, 200, 300}, 5000), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []segmentRef{
+		{ByteRange{50, 149}, 0, 5 * time.Second},
+		{ByteRange{150, 349}, 5 * time.Second, 5 * time.Second},
+		{ByteRange{350, 649}, 10 * time.Second, 5 * time.Second},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("got %d segments, want %d", len(refs), len(want))
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("segment %d: got %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+	if _, err = parseSidx([]byte("\x00\x00\x00\x08moov"), 0); err == nil {
+		t.Error("expected an error for a box that is not sidx")
+	}
+}
+
+func ebml(id []byte, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	b := append([]byte{}, id...)
+	// eight byte size
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	return append(append(b, size...), body...)
+}
+
+func TestParseCues(t *testing.T) {
+	header := bytes.Join([][]byte{
+		ebml([]byte{0x1a, 0x45, 0xdf, 0xa3}, []byte{0x42, 0x86, 0x81, 0x01}),
+		{0x18, 0x53, 0x80, 0x67, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		ebml([]byte{0x15, 0x49, 0xa9, 0x66}, ebml([]byte{0x2a, 0xd7, 0xb1}, []byte{0x0f, 0x42, 0x40})),
+	}, nil)
+	segmentOffset, scale, err := parseWebMHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segmentOffset != 28 || scale != 1000000 {
+		t.Errorf("got offset %d and scale %d", segmentOffset, scale)
+	}
+
+	cue := func(ms, pos byte) []byte {
+		return ebml([]byte{0xbb},
+			ebml([]byte{0xb3}, []byte{0x03, ms}),
+			ebml([]byte{0xb7}, ebml([]byte{0xf7}, []byte{1}), ebml([]byte{0xf1}, []byte{pos})),
+		)
+	}
+	cues := ebml([]byte{0x1c, 0x53, 0xbb, 0x6b}, cue(0x00, 0x10), cue(0xe8, 0x80))
+	refs, err := parseCues(cues, segmentOffset, scale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []segmentRef{
+		{ByteRange{28 + 0x10, 28 + 0x80 - 1}, 768 * time.Millisecond, 232 * time.Millisecond},
+		{ByteRange{28 + 0x80, -1}, time.Second, 0},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("got %d segments, want %d", len(refs), len(want))
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("segment %d: got %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+}
+
+func TestSelectSegments(t *testing.T) {
+	var refs []segmentRef
+	for i := 0; i < 4; i++ {
+		refs = append(refs, segmentRef{time: time.Duration(i) * 10 * time.Second, duration: 10 * time.Second})
+	}
+	tests := []struct {
+		start, end  time.Duration
+		first, last int
+	}{
+		{0, 0, 0, 3},
+		{15 * time.Second, 0, 1, 3},
+		{15 * time.Second, 25 * time.Second, 1, 2},
+		{10 * time.Second, 20 * time.Second, 1, 1},
+		{35 * time.Second, 0, 3, 3},
+		{50 * time.Second, 0, -1, -1},
+	}
+	for _, tt := range tests {
+		first, last := selectSegments(refs, tt.start, tt.end)
+		if first != tt.first || last != tt.last {
+			t.Errorf("(%s, %s): got %d-%d, want %d-%d", tt.start, tt.end, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestDownloadRange(t *testing.T) {
+	var (
+		init  = []byte("\x00\x00\x00\x10ftypdash\x00\x00\x00\x00")
+		sizes = []uint32{10, 10, 10, 10}
+		sidx  = sidxBox(1, sizes, 10)
+		media = []byte(strings.Repeat("a", 10) + strings.Repeat("b", 10) + strings.Repeat("c", 10) + strings.Repeat("d", 10))
+		data  = bytes.Join([][]byte{init, sidx, media}, nil)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	s := &Stream{
+		URL:        srv.URL,
+		InitRange:  &ByteRange{0, int64(len(init) - 1)},
+		IndexRange: &ByteRange{int64(len(init)), int64(len(init) + len(sidx) - 1)},
+	}
+	dir, err := ioutil.TempDir("", "yt-range")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "clip.mp4")
+
+	offset, err := s.DownloadRange(fname, 15*time.Second, 25*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 10*time.Second {
+		t.Errorf("got segment start %s, want 10s", offset)
+	}
+	got, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := string(init) + strings.Repeat("b", 10) + strings.Repeat("c", 10)
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err = (&Stream{URL: srv.URL}).DownloadRange(fname, 0, 0); err != ErrNoIndex {
+		t.Errorf("expected ErrNoIndex, got %v", err)
+	}
+	// ranges from the player that do not fit together
+	for _, r := range [][2]ByteRange{
+		{{0, 200}, *s.IndexRange},
+		{*s.InitRange, {s.IndexRange.End, s.IndexRange.Start}},
+		{{-1, 5}, *s.IndexRange},
+	} {
+		bad := &Stream{URL: srv.URL, InitRange: &r[0], IndexRange: &r[1]}
+		if _, err = bad.DownloadRange(fname, 0, 0); err != ErrNoIndex {
+			t.Errorf("%v: expected ErrNoIndex, got %v", r, err)
+		}
+	}
+
+	// nothing is left behind when the download fails
+	failed := filepath.Join(dir, "failed.mp4")
+	if _, err = s.DownloadRange(failed, time.Minute, 0); err == nil {
+		t.Fatal("expected an error for a range outside of the stream")
+	}
+	for _, name := range []string{failed, failed + ".part"} {
+		if _, err = os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist after a failed download", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package youtube
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// Info is the serialized description of a video that is saved next to
+// downloaded files. It contains all of the video's metadata along with the
+// stream that was chosen for the download.
+type Info struct {
+	*Video
+	// Stream is the stream that was downloaded.
+	Stream *Stream `json:"stream,omitempty"`
+}
+
+// WriteInfo writes the video's metadata as json. The chosen stream may be nil.
+func (v *Video) WriteInfo(w io.Writer, chosen *Stream) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&Info{Video: v, Stream: chosen})
+}
+
+// SaveInfo writes the video's metadata to a file.
+func (v *Video) SaveInfo(filename string, chosen *Stream) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = v.WriteInfo(file, chosen); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadInfo reads a video's metadata that was written by WriteInfo.
+func ReadInfo(r io.Reader) (*Info, error) {
+	info := &Info{Video: &Video{}}
+	if err := json.NewDecoder(r).Decode(info); err != nil {
+		return nil, err
+	}
+	if info.ID == "" {
+		return nil, errors.New("info has no video id")
+	}
+	if info.FileName == "" {
+		info.FileName = SafeFileName(info.Title)
+	}
+	return info, nil
+}
+
+// LoadInfo creates a Video from a file written by SaveInfo without
+// querying youtube. Stream urls expire after a few hours so the streams
+// of old info files may no longer be downloadable.
+func LoadInfo(filename string) (*Video, error) {
+	return DefaultClient.LoadInfo(filename)
+}
+
+// LoadInfo creates a Video from a file written by SaveInfo. The video's
+// streams are downloaded with the client.
+func (c *Client) LoadInfo(filename string) (*Video, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := ReadInfo(file)
+	if err != nil {
+		return nil, err
+	}
+	info.Video.setClient(c)
+	return info.Video, nil
+}
@@ -0,0 +1,65 @@
+package youtube
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestInfoRoundTrip(t *testing.T) {
+	var vd VideoData
+	err := json.Unmarshal([]byte(`{
+		"streamingData": {"formats": [{
+			"url": "https://example.com/video",
+			"mimeType": "video/mp4; codecs=\"avc1.42001E, mp4a.40.2\"",
+			"width": 640, "height": 360, "bitrate": 500000,
+			"contentLength": "1024"
+		}]},
+		"videoDetails": {
+			"videoId": "O9Ks3_8Nq1s", "title": "a: video", "lengthSeconds": "300",
+			"shortDescription": "0:00 start\n1:00 end"
+		},
+		"playabilityStatus": {"status": "OK"}
+	}`), &vd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(vd)
+	v := &Video{}
+	if err = initVideoData(raw, v); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = v.WriteInfo(&buf, v.BestStream()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ReadInfo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != v.ID || info.Title != v.Title || info.FileName != "a video" {
+		t.Errorf("wrong metadata: %+v", info.Video)
+	}
+	if info.Stream == nil || info.Stream.URL != "https://example.com/video" {
+		t.Fatal("chosen stream was not saved")
+	}
+	mt := info.Stream.MimeType
+	if mt.ContentType != "video/mp4" || len(mt.Codecs) != 2 || mt.Codecs[1] != "mp4a.40.2" {
+		t.Errorf("wrong mime type: %+v", mt)
+	}
+	if len(info.Streams) != 1 || !info.Streams[0].IsDualStream() {
+		t.Error("streams were not saved")
+	}
+	if len(info.Chapters) != 2 || info.Chapters[1].Start != time.Minute || info.Chapters[1].End != 5*time.Minute {
+		t.Errorf("wrong chapters: %+v", info.Chapters)
+	}
+	if len(info.Thumbnails) != len(v.Thumbnails) {
+		t.Error("thumbnails were not saved")
+	}
+
+	if _, err = ReadInfo(bytes.NewBufferString(`{"title": "no id"}`)); err == nil {
+		t.Error("expected an error for info without an id")
+	}
+}
@@ -1,6 +1,9 @@
 package youtube
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Represents video meta-data for a youtube video
 type baseVideo struct {
@@ -10,6 +13,11 @@ type baseVideo struct {
 	Title         string `json:"title"`
 	ID            string `json:"videoId"`
 	ViewCount     string `json:"viewCount"`
+	Description   string `json:"shortDescription"`
+	IsLive        bool   `json:"isLive,omitempty"`
+	IsUpcoming    bool   `json:"isUpcoming,omitempty"`
+	IsLiveContent bool   `json:"isLiveContent,omitempty"`
+	IsLiveDVR     bool   `json:"isLiveDvrEnabled,omitempty"`
 }
 
 // The VideoData struct is an intermediate struct between the video's raw json string
@@ -19,6 +27,8 @@ type VideoData struct {
 		AdaptiveFormats  []Stream `json:"adaptiveFormats"`
 		Formats          []Stream `json:"formats"`
 		ExpiresInSeconds string   `json:"expiresInSeconds"`
+		HLSManifestURL   string   `json:"hlsManifestUrl"`
+		DASHManifestURL  string   `json:"dashManifestUrl"`
 	} `json:"streamingData"`
 	VideoDetails struct {
 		baseVideo
@@ -27,6 +37,20 @@ type VideoData struct {
 			Thumbnails []Thumbnail
 		}
 	} `json:"videoDetails"`
+	Microformat struct {
+		PlayerMicroformatRenderer struct {
+			PublishDate string `json:"publishDate"`
+			UploadDate  string `json:"uploadDate"`
+			Category    string `json:"category"`
+
+			LiveBroadcastDetails struct {
+				IsLiveNow      bool      `json:"isLiveNow"`
+				StartTimestamp time.Time `json:"startTimestamp"`
+				EndTimestamp   time.Time `json:"endTimestamp"`
+			} `json:"liveBroadcastDetails"`
+		} `json:"playerMicroformatRenderer"`
+	} `json:"microformat"`
+	PlayerOverlays    playerChapters     `json:"playerOverlays"`
 	PlayabilityStatus *playabilityStatus `json:"playabilityStatus"`
 }
 
@@ -42,6 +42,11 @@ type Playlist struct {
 
 // NewPlaylist creates a playlist object from a playlist id.
 func NewPlaylist(id string) (*Playlist, error) {
+	return DefaultClient.NewPlaylist(id)
+}
+
+// NewPlaylist creates a playlist object from a playlist id.
+func (c *Client) NewPlaylist(id string) (*Playlist, error) {
 	var req = http.Request{
 		Method:     "GET",
 		Proto:      "HTTP/1.1",
@@ -62,7 +67,7 @@ func NewPlaylist(id string) (*Playlist, error) {
 			}.Encode(),
 		},
 	}
-	resp, err := client.Do(&req)
+	resp, err := c.do(&req)
 	if err != nil {
 		return nil, err
 	}
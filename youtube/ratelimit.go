@@ -0,0 +1,97 @@
+package youtube
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that limits the number of bytes per second
+// written through it. It can be shared by many downloads.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	rate := float64(bytesPerSecond)
+	burst := rate / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until n bytes can be written. Tokens are taken before
+// waiting so that waiting writers are served in order.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(d)
+}
+
+// chunk is the largest write that is made at once so that a large write
+// does not take the whole bucket.
+func (l *rateLimiter) chunk() int {
+	c := int(l.burst)
+	if c > 32*1024 {
+		c = 32 * 1024
+	}
+	return c
+}
+
+type limitedWriter struct {
+	w        io.Writer
+	limiters []*rateLimiter
+}
+
+func (lw *limitedWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		size := len(p)
+		for _, l := range lw.limiters {
+			if c := l.chunk(); c < size {
+				size = c
+			}
+		}
+		for _, l := range lw.limiters {
+			l.wait(size)
+		}
+		m, err := lw.w.Write(p[:size])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[size:]
+	}
+	return n, nil
+}
+
+// limit wraps a writer for a single download so that it is limited by
+// the client's shared rate limit and its limit for each download.
+func (c *Client) limit(w io.Writer) io.Writer {
+	var limiters []*rateLimiter
+	if c.limiter != nil {
+		limiters = append(limiters, c.limiter)
+	}
+	if c.downloadRate > 0 {
+		limiters = append(limiters, newRateLimiter(c.downloadRate))
+	}
+	if len(limiters) == 0 {
+		return w
+	}
+	return &limitedWriter{w: w, limiters: limiters}
+}
@@ -0,0 +1,55 @@
+package youtube
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	const rate = 200000
+	data := make([]byte, 100000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&ClientConfig{RateLimit: rate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two downloads share the client's limit
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			n, err := Stream{URL: srv.URL, client: c}.WriteTo(&buf)
+			if err != nil || n != int64(len(data)) {
+				t.Errorf("wrote %d bytes: %v", n, err)
+			}
+		}()
+	}
+	wg.Wait()
+	// 200KB at 200KB/s less the initial burst of 20KB
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("shared limit took %s, expected about 900ms", elapsed)
+	}
+
+	if c, err = NewClient(&ClientConfig{DownloadRateLimit: rate}); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if _, err = (Stream{URL: srv.URL, client: c}).WriteTo(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("download limit took %s, expected about 450ms", elapsed)
+	}
+}
@@ -3,6 +3,7 @@ package youtube
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -17,7 +18,7 @@ import (
 type Stream struct {
 	// to be honest I don't know what this is but it's important for
 	// determining whether the stream is a video or audio stream, or both.
-	MimeType MimeType
+	MimeType MimeType `json:"mimeType"`
 	// The url for the raw video data
 	URL string `json:"url"`
 	// Height of the video in pixels.
@@ -28,19 +29,99 @@ type Stream struct {
 	Width int `json:"width"`
 	// Bitrate of the video's audio.
 	Bitrate int `json:"bitrate"`
+	// ITag is youtube's identifier for the stream's format.
+	ITag int `json:"itag"`
+	// QualityLabel is a human readable video quality such as "720p".
+	// It is empty for audio-only streams.
+	QualityLabel string `json:"qualityLabel,omitempty"`
+	FPS          int    `json:"fps,omitempty"`
 	// size of the video
 	ContentLength string `json:"contentLength"`
 
 	SignatureCipher string `json:"signatureCipher"`
+
+	// InitRange is the range of bytes containing the stream's
+	// initialization data and IndexRange is the range of bytes containing
+	// the index of the stream's segments. Only adaptive streams have them.
+	InitRange  *ByteRange `json:"initRange,omitempty"`
+	IndexRange *ByteRange `json:"indexRange,omitempty"`
+
+	// Initialization is the url of the initialization segment for
+	// streams that are split into segments.
+	Initialization string `json:"initialization,omitempty"`
+	// Segments are the urls of each media segment in order for streams
+	// that come from a DASH manifest. Segmented streams have no URL.
+	Segments []string `json:"segments,omitempty"`
+
+	client *Client
 }
 
-// WriteTo will write the stream data to an io.Writer
+// WriteTo will write the stream data to an io.Writer. The speed is
+// limited by the rate limits of the stream's client. The data is checked
+// against the stream's length and mime type and an *IntegrityError is
+// returned if they do not match.
 func (s Stream) WriteTo(w io.Writer) (int64, error) {
-	resp, err := client.Get(s.URL)
+	c := clientOr(s.client)
+	v := &verifier{w: c.limit(w)}
+	var err error
+	if s.Segmented() {
+		_, err = s.writeSegments(v)
+	} else {
+		err = s.copyTo(c, v)
+	}
+	if err != nil {
+		return v.n, err
+	}
+	return v.n, s.verify(v)
+}
+
+func (s Stream) copyTo(c *Client, w io.Writer) error {
+	u, err := s.GetURL()
+	if err != nil {
+		return err
+	}
+	resp, err := c.get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("could not download stream: %s", resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Segmented returns true if the stream's data is split into segments.
+func (s Stream) Segmented() bool {
+	return len(s.Segments) > 0
+}
+
+func (s Stream) writeSegments(w io.Writer) (n int64, err error) {
+	urls := s.Segments
+	if s.Initialization != "" {
+		urls = append([]string{s.Initialization}, urls...)
+	}
+	for _, u := range urls {
+		var written int64
+		written, err = clientOr(s.client).writeSegment(w, u)
+		n += written
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (c *Client) writeSegment(w io.Writer, u string) (int64, error) {
+	resp, err := c.get(u)
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("could not get segment %s: %s", u, resp.Status)
+	}
 	return io.Copy(w, resp.Body)
 }
 
@@ -95,27 +176,23 @@ func GetBestStream(c []Stream) *Stream {
 }
 
 // DownloadFromStream accepts a stream and downloads it to a given file name.
+// The data is written to a ".part" file which is only renamed to the file
+// name once the whole stream has been downloaded.
 func DownloadFromStream(s *Stream, fname string) error {
-	url, err := s.GetURL()
+	part := fname + ".part"
+	file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
-	resp, err := client.Do(&http.Request{
-		Method: "GET",
-		Proto:  "HTTP/1.1",
-		URL:    url,
-	})
-	if err != nil {
-		return err
+	_, err = s.WriteTo(file)
+	if e := file.Close(); err == nil {
+		err = e
 	}
-	defer resp.Body.Close()
-	file, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
+		os.Remove(part)
 		return err
 	}
-	defer file.Close()
-	_, err = io.Copy(file, resp.Body)
-	return err
+	return os.Rename(part, fname)
 }
 
 // Streams is a slice of streams that is sorted by width and height
@@ -157,12 +234,24 @@ var (
 // json.Unmarshaler interface
 func (m *MimeType) UnmarshalJSON(b []byte) error {
 	result := mimeTypeRegex.FindAllStringSubmatch(string(b), -1)
+	if len(result) == 0 {
+		return fmt.Errorf("could not parse mime type %s", b)
+	}
 	m.ContentType = result[0][1]
 	m.Codecs = strings.Split(result[0][2], ", ")
 	return nil
 }
 
-var _ json.Unmarshaler = (*MimeType)(nil)
+// String returns the mime type in the same format used by youtube.
+func (m MimeType) String() string {
+	return fmt.Sprintf(`%s; codecs="%s"`, m.ContentType, strings.Join(m.Codecs, ", "))
+}
+
+// MarshalJSON makes the MimeType struct implement the json.Marshaler
+// interface.
+func (m MimeType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
 
 func splitMimeType(s string) (string, []string) {
 	match := codecsRegex.Split(s, 2)
@@ -204,5 +293,6 @@ func sortStreams(streams []Stream) ([]Stream, []Stream) {
 var (
 	_ io.WriterTo      = (*Stream)(nil)
 	_ json.Unmarshaler = (*MimeType)(nil)
+	_ json.Marshaler   = (*MimeType)(nil)
 	_ sort.Interface   = (*Streams)(nil)
 )
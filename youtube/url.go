@@ -0,0 +1,185 @@
+package youtube
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URL is a parsed youtube link.
+type URL struct {
+	VideoID    string
+	PlaylistID string
+	ChannelID  string
+	// Handle is a channel handle such as "@name" or a legacy channel
+	// path such as "c/name" or "user/name".
+	Handle string
+	// Start is the time given by the link's "t" or "start" parameter.
+	Start time.Duration
+}
+
+// IsVideo returns true if the url links to a video. Links to a video in a
+// playlist are both videos and playlists.
+func (u *URL) IsVideo() bool { return u.VideoID != "" }
+
+// IsPlaylist returns true if the url has a playlist id.
+func (u *URL) IsPlaylist() bool { return u.PlaylistID != "" }
+
+// IsChannel returns true if the url links to a channel.
+func (u *URL) IsChannel() bool { return u.ChannelID != "" || u.Handle != "" }
+
+// Channel returns the channel id or handle.
+func (u *URL) Channel() string {
+	if u.ChannelID != "" {
+		return u.ChannelID
+	}
+	return u.Handle
+}
+
+var (
+	videoIDRegex    = regexp.MustCompile(`^[\w-]{11}$`)
+	playlistIDRegex = regexp.MustCompile(`^(?:PL|UU|LL|FL|OL|RD|UL|PU)[\w-]{10,}$`)
+	handleRegex     = regexp.MustCompile(`^@[\w.-]+$`)
+	timestampRegex  = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s?)?$`)
+
+	youtubeHosts = map[string]bool{
+		"youtube.com":              true,
+		"www.youtube.com":          true,
+		"m.youtube.com":            true,
+		"music.youtube.com":        true,
+		"gaming.youtube.com":       true,
+		"youtube-nocookie.com":     true,
+		"www.youtube-nocookie.com": true,
+	}
+)
+
+// ParseURL parses any of the forms of youtube link. Bare video ids,
+// playlist ids, channel ids and channel handles are also accepted.
+func ParseURL(s string) (*URL, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case IsChannelID(s):
+		return &URL{ChannelID: s}, nil
+	case playlistIDRegex.MatchString(s):
+		return &URL{PlaylistID: s}, nil
+	case videoIDRegex.MatchString(s):
+		return &URL{VideoID: s}, nil
+	case handleRegex.MatchString(s):
+		return &URL{Handle: s}, nil
+	}
+
+	raw := s
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + strings.TrimPrefix(raw, "//")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid youtube url %q", s)
+	}
+	var result *URL
+	switch host := strings.ToLower(u.Hostname()); {
+	case host == "youtu.be" || host == "www.youtu.be":
+		result = &URL{VideoID: firstPathPart(u.Path)}
+	case youtubeHosts[host] && firstPathPart(u.Path) == "attribution_link":
+		// the real link is in the 'u' parameter along with its own query
+		link := u.Query().Get("u")
+		if link == "" {
+			return nil, fmt.Errorf("no link in %q", s)
+		}
+		ref, err := u.Parse(link)
+		if err != nil {
+			return nil, fmt.Errorf("invalid youtube url %q", s)
+		}
+		return ParseURL(ref.String())
+	case youtubeHosts[host]:
+		result, err = parseYoutubePath(u)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%q is not a youtube url", s)
+	}
+	q := u.Query()
+	if result.PlaylistID == "" {
+		result.PlaylistID = q.Get("list")
+	}
+	if t := q.Get("t"); t != "" {
+		result.Start = parseURLTimestamp(t)
+	} else if t = q.Get("start"); t != "" {
+		result.Start = parseURLTimestamp(t)
+	} else if strings.HasPrefix(u.Fragment, "t=") {
+		result.Start = parseURLTimestamp(u.Fragment[2:])
+	}
+	if result.VideoID != "" && !videoIDRegex.MatchString(result.VideoID) {
+		return nil, fmt.Errorf("invalid video id in %q", s)
+	}
+	if !result.IsVideo() && !result.IsPlaylist() && !result.IsChannel() {
+		return nil, fmt.Errorf("could not find a video, playlist or channel in %q", s)
+	}
+	return result, nil
+}
+
+func parseYoutubePath(u *url.URL) (*URL, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch parts[0] {
+	case "watch":
+		return &URL{VideoID: u.Query().Get("v")}, nil
+	case "playlist":
+		return &URL{}, nil
+	case "shorts", "live", "v", "e":
+		return &URL{VideoID: part(parts, 1)}, nil
+	case "embed":
+		if id := part(parts, 1); id != "videoseries" {
+			return &URL{VideoID: id}, nil
+		}
+		return &URL{}, nil
+	case "channel":
+		id := part(parts, 1)
+		if !IsChannelID(id) {
+			return nil, fmt.Errorf("invalid channel id %q", id)
+		}
+		return &URL{ChannelID: id}, nil
+	case "c", "user":
+		if name := part(parts, 1); name != "" {
+			return &URL{Handle: parts[0] + "/" + name}, nil
+		}
+	default:
+		if handleRegex.MatchString(parts[0]) {
+			return &URL{Handle: parts[0]}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown youtube url %q", u.String())
+}
+
+// parseURLTimestamp parses the timestamps used in the 't' parameter
+// which are either a number of seconds or of the form "1h2m3s".
+func parseURLTimestamp(t string) time.Duration {
+	m := timestampRegex.FindStringSubmatch(t)
+	if m == nil {
+		return 0
+	}
+	var d time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+1])
+		d += time.Duration(n) * unit
+	}
+	return d
+}
+
+func firstPathPart(p string) string {
+	return part(strings.Split(strings.Trim(p, "/"), "/"), 0)
+}
+
+func part(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package youtube
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want URL
+	}{
+		{"https://www.youtube.com/watch?v=kJQP7kiw5Fk", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"https://www.youtube.com/watch?v=HaeH6KYCcmM", URL{VideoID: "HaeH6KYCcmM"}},
+		{"www.youtube.com/watch?v=kJQP7kiw5Fk", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"//m.youtube.com/watch?v=kJQP7kiw5Fk&feature=share", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"kJQP7kiw5Fk", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"https://youtu.be/kJQP7kiw5Fk?t=90", URL{VideoID: "kJQP7kiw5Fk", Start: 90 * time.Second}},
+		{"https://youtu.be/kJQP7kiw5Fk?list=PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo", URL{VideoID: "kJQP7kiw5Fk", PlaylistID: "PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo"}},
+		{"https://www.youtube.com/shorts/kJQP7kiw5Fk", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"https://www.youtube.com/live/kJQP7kiw5Fk?si=abc", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"https://music.youtube.com/watch?v=kJQP7kiw5Fk&list=RDAMVMkJQP7kiw5Fk", URL{VideoID: "kJQP7kiw5Fk", PlaylistID: "RDAMVMkJQP7kiw5Fk"}},
+		{"https://www.youtube.com/embed/kJQP7kiw5Fk?start=65", URL{VideoID: "kJQP7kiw5Fk", Start: 65 * time.Second}},
+		{"https://www.youtube-nocookie.com/embed/kJQP7kiw5Fk", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"https://www.youtube.com/embed/videoseries?list=PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo", URL{PlaylistID: "PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo"}},
+		{"https://www.youtube.com/v/kJQP7kiw5Fk", URL{VideoID: "kJQP7kiw5Fk"}},
+		{"https://www.youtube.com/attribution_link?a=abc&u=%2Fwatch%3Fv%3DkJQP7kiw5Fk%26feature%3Dshare", URL{VideoID: "kJQP7kiw5Fk"}},
+		{
+			"https://www.youtube.com/attribution_link?a=abc&u=%2Fwatch%3Fv%3DkJQP7kiw5Fk%26list%3DPLFsQleAWXsj_4yDeebiIADdH5FMayBiJo%26t%3D90",
+			URL{VideoID: "kJQP7kiw5Fk", PlaylistID: "PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo", Start: 90 * time.Second},
+		},
+		{
+			"https://www.youtube.com/watch?v=kJQP7kiw5Fk&list=PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo&index=3&t=1h2m3s",
+			URL{VideoID: "kJQP7kiw5Fk", PlaylistID: "PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo", Start: time.Hour + 2*time.Minute + 3*time.Second},
+		},
+		{"https://www.youtube.com/watch?v=kJQP7kiw5Fk#t=2m", URL{VideoID: "kJQP7kiw5Fk", Start: 2 * time.Minute}},
+		{"https://www.youtube.com/playlist?list=PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi", URL{PlaylistID: "PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi"}},
+		{"PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi", URL{PlaylistID: "PLy2PCKGkKRVaSxaWg9_N6wuQ2kTpF3tIi"}},
+		{"https://www.youtube.com/channel/UCBR8-60-B28hp2BmDPdntcQ/videos", URL{ChannelID: "UCBR8-60-B28hp2BmDPdntcQ"}},
+		{"UCBR8-60-B28hp2BmDPdntcQ", URL{ChannelID: "UCBR8-60-B28hp2BmDPdntcQ"}},
+		{"https://www.youtube.com/@YouTube", URL{Handle: "@YouTube"}},
+		{"@YouTube", URL{Handle: "@YouTube"}},
+		{"https://www.youtube.com/c/YouTubeCreators", URL{Handle: "c/YouTubeCreators"}},
+		{"https://www.youtube.com/user/someone/videos", URL{Handle: "user/someone"}},
+	}
+	for _, tt := range tests {
+		u, err := ParseURL(tt.url)
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if *u != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.url, *u, tt.want)
+		}
+	}
+}
+
+func TestParseURL_Err(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"https://example.com/watch?v=kJQP7kiw5Fk",
+		"https://www.youtube.com/watch",
+		"https://www.youtube.com/watch?v=tooshort",
+		"https://www.youtube.com/channel/notachannel",
+		"https://www.youtube.com/feed/trending",
+		"https://www.youtube.com/attribution_link?a=abc",
+		"https://www.youtube.com/attribution_link?u=https%3A%2F%2Fexample.com%2Fwatch%3Fv%3DkJQP7kiw5Fk",
+		"not a url at all",
+	} {
+		if u, err := ParseURL(s); err == nil {
+			t.Errorf("expected an error for %q, got %+v", s, u)
+		}
+	}
+}
+
+func TestURLKind(t *testing.T) {
+	u := &URL{VideoID: "kJQP7kiw5Fk", PlaylistID: "PLFsQleAWXsj_4yDeebiIADdH5FMayBiJo"}
+	if !u.IsVideo() || !u.IsPlaylist() || u.IsChannel() {
+		t.Errorf("wrong kind for %+v", u)
+	}
+	u = &URL{Handle: "@YouTube"}
+	if u.IsVideo() || !u.IsChannel() || u.Channel() != "@YouTube" {
+		t.Errorf("wrong kind for %+v", u)
+	}
+}
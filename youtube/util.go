@@ -8,15 +8,6 @@ import (
 	"strings"
 )
 
-var (
-	client = &http.Client{
-		Transport: &userAgentTransport{
-			agent: agent,
-			inner: http.DefaultTransport,
-		},
-	}
-)
-
 const (
 	badchars = `\/:*?"<>|.`
 	agent    = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/500.0 (KHTML, like Gecko) Chrome/70.0.0.0 Safari/500.0"
@@ -32,7 +23,9 @@ func (uat *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, err
 	return uat.inner.RoundTrip(req)
 }
 
-func safeFileName(name string) string {
+// SafeFileName removes the characters from name that cannot be used in a
+// file name. It is how the file names of videos are made from their titles.
+func SafeFileName(name string) string {
 	for i := range badchars {
 		if strings.Contains(name, string(badchars[i])) {
 			name = strings.Replace(name, string(badchars[i]), "", -1)
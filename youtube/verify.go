@@ -0,0 +1,72 @@
+package youtube
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// IntegrityError is returned when downloaded data does not match the
+// stream it was downloaded from.
+type IntegrityError struct {
+	Reason string
+}
+
+func (e *IntegrityError) Error() string {
+	return "download failed verification: " + e.Reason
+}
+
+// verifier counts the bytes written through it and keeps the first few
+// so the container can be checked.
+type verifier struct {
+	w    io.Writer
+	n    int64
+	head []byte
+}
+
+const headSize = 12
+
+func (v *verifier) Write(p []byte) (int, error) {
+	if len(v.head) < headSize {
+		need := headSize - len(v.head)
+		if need > len(p) {
+			need = len(p)
+		}
+		v.head = append(v.head, p[:need]...)
+	}
+	n, err := v.w.Write(p)
+	v.n += int64(n)
+	return n, err
+}
+
+// verify checks the data written for a stream. Segmented streams usually
+// have no content length so only their container is checked.
+func (s Stream) verify(v *verifier) error {
+	if s.ContentLength != "" && !s.Segmented() {
+		want, err := strconv.ParseInt(s.ContentLength, 10, 64)
+		if err == nil && v.n != want {
+			return &IntegrityError{fmt.Sprintf("got %d of %d bytes", v.n, want)}
+		}
+	}
+	return checkContainer(s.MimeType.ContentType, v.head)
+}
+
+// checkContainer makes sure that the start of a file matches the
+// container format of its mime type. Unknown formats are not checked.
+func checkContainer(contentType string, head []byte) error {
+	var ok bool
+	switch format := contentType[strings.Index(contentType, "/")+1:]; format {
+	case "mp4", "3gpp":
+		ok = len(head) >= 8 && string(head[4:8]) == "ftyp"
+	case "webm":
+		ok = bytes.HasPrefix(head, ebmlMagic)
+	default:
+		return nil
+	}
+	if ok {
+		return nil
+	}
+	return &IntegrityError{fmt.Sprintf("expected a %s file but got data starting with %q", contentType, head)}
+}
@@ -0,0 +1,79 @@
+package youtube
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDownloadVerification(t *testing.T) {
+	mp4 := append([]byte("\x00\x00\x00\x18ftypmp42"), make([]byte, 100)...)
+	webm := append(append([]byte{}, ebmlMagic...), make([]byte, 100)...)
+	html := []byte("<!DOCTYPE html><html><body>error</body></html>")
+
+	var body []byte
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "yt-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		status    int
+		body      []byte
+		mime      string
+		length    int
+		integrity bool
+		ok        bool
+	}{
+		{"mp4", 200, mp4, "video/mp4", len(mp4), false, true},
+		{"webm", 200, webm, "audio/webm", len(webm), false, true},
+		{"partial content", 206, mp4, "video/mp4", len(mp4), false, true},
+		{"no length", 200, mp4, "video/mp4", 0, false, true},
+		{"truncated", 200, mp4[:50], "video/mp4", len(mp4), true, false},
+		{"html page", 200, html, "video/mp4", 0, true, false},
+		{"wrong container", 200, webm, "video/mp4", len(webm), true, false},
+		{"forbidden", 403, html, "video/mp4", 0, false, false},
+	}
+	for _, tt := range tests {
+		status, body = tt.status, tt.body
+		s := &Stream{URL: srv.URL, MimeType: MimeType{ContentType: tt.mime}}
+		if tt.length > 0 {
+			s.ContentLength = strconv.Itoa(tt.length)
+		}
+		fname := filepath.Join(dir, tt.name)
+		err := DownloadFromStream(s, fname)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if b, _ := ioutil.ReadFile(fname); !bytes.Equal(b, tt.body) {
+				t.Errorf("%s: wrong file contents", tt.name)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		for _, name := range []string{fname, fname + ".part"} {
+			if _, e := os.Stat(name); !os.IsNotExist(e) {
+				t.Errorf("%s: a failed download left %s behind", tt.name, filepath.Base(name))
+			}
+		}
+		if _, isIntegrity := err.(*IntegrityError); isIntegrity != tt.integrity {
+			t.Errorf("%s: got error %T %v", tt.name, err, err)
+		}
+	}
+}
@@ -10,7 +10,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
+	"sort"
+	"strconv"
+	"time"
 )
 
 var (
@@ -24,20 +28,74 @@ type Video struct {
 	baseVideo
 
 	// FileName is a file system safe version of the video's title.
-	FileName string
+	FileName string `json:"fileName"`
 	// A slice of stream objects containing both audio and video
-	Streams Streams
+	Streams Streams `json:"streams"`
 	// A slice of streams containing only video
-	VideoStreams Streams
+	VideoStreams Streams `json:"videoStreams"`
 	// A slice of streams containing only audio
-	AudioStreams AudioStreams
-	Thumbnails   []Thumbnail
+	AudioStreams AudioStreams `json:"audioStreams"`
+	Thumbnails   []Thumbnail  `json:"thumbnails"`
+	// Chapters are the sections of the video from its chapter markers or
+	// the timestamps in its description. Chapters will be empty if the
+	// video has no chapters.
+	Chapters []Chapter `json:"chapters,omitempty"`
+	Keywords []string  `json:"keywords,omitempty"`
+	// PublishDate is the date the video was published formatted
+	// as YYYY-MM-DD.
+	PublishDate string `json:"publishDate,omitempty"`
+	Category    string `json:"category,omitempty"`
+
+	// PlaylistIndex is the position of the video in the playlist that it
+	// is downloaded from, counting from 1. It is zero for single videos.
+	PlaylistIndex int `json:"playlistIndex,omitempty"`
+	// PlaylistTitle is the title of the playlist that the video is
+	// downloaded from.
+	PlaylistTitle string `json:"playlistTitle,omitempty"`
+
+	// HLSManifestURL is the url of the HLS playlist used for live streams.
+	HLSManifestURL string `json:"hlsManifestUrl,omitempty"`
+	// DASHManifestURL is the url of the video's DASH manifest.
+	DASHManifestURL string `json:"dashManifestUrl,omitempty"`
+	// ScheduledStart is the time that an upcoming live stream or premiere
+	// is scheduled to start.
+	ScheduledStart time.Time `json:"scheduledStart"`
+
+	playability *playabilityStatus
+	client      *Client
+	// dashAdded is true once the streams from the DASH manifest are added
+	dashAdded bool
 }
 
 // NewVideo creates and returns a new Video object.
 func NewVideo(id string) (*Video, error) {
-	vid := &Video{}
-	r, err := info(id)
+	return DefaultClient.NewVideo(id)
+}
+
+// NewVideo creates and returns a new Video object.
+func (c *Client) NewVideo(id string) (*Video, error) {
+	vid, err := c.LookupVideo(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = vid.PlayabilityError(); err != nil {
+		return nil, err
+	}
+	return vid, nil
+}
+
+// LookupVideo gets a video's metadata the same way as NewVideo but does not
+// fail when the video cannot be played. Use Playable to find out if the
+// video's streams can be downloaded.
+func LookupVideo(id string) (*Video, error) {
+	return DefaultClient.LookupVideo(id)
+}
+
+// LookupVideo gets a video's metadata without failing when the video
+// cannot be played. See the LookupVideo function.
+func (c *Client) LookupVideo(id string) (*Video, error) {
+	vid := &Video{client: c}
+	r, err := c.info(id)
 	if err != nil {
 		return nil, err
 	}
@@ -59,47 +117,137 @@ func NewVideo(id string) (*Video, error) {
 	if err != nil {
 		// if the first try failed, try getting the data from
 		// the html
-		var conf *ytConfig
-		conf, err = videoDataFromHTML(id)
-		if err != nil {
-			return nil, err
+		conf, e := c.videoDataFromHTML(id)
+		if e == nil {
+			err = initVideoData([]byte(conf.Args.PlayerResponse), vid)
+		} else if _, ok := err.(*playabilityStatus); !ok {
+			return nil, e
 		}
-		err = initVideoData([]byte(conf.Args.PlayerResponse), vid)
 	}
-	return vid, err
+	if _, ok := err.(*playabilityStatus); ok {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	vid.setClient(c)
+	return vid, nil
+}
+
+// setClient makes the video's streams and thumbnails download
+// with the client.
+func (v *Video) setClient(c *Client) {
+	v.client = c
+	for _, streams := range [][]Stream{v.Streams, v.VideoStreams, v.AudioStreams} {
+		for i := range streams {
+			streams[i].client = c
+		}
+	}
+	for i := range v.Thumbnails {
+		v.Thumbnails[i].client = c
+	}
+}
+
+// Playable returns true if the video's streams can be downloaded.
+func (v *Video) Playable() bool {
+	return v.playability == nil || v.playability.Status == "OK"
+}
+
+// PlayabilityError returns an error describing why the video cannot be
+// played or nil if the video is playable.
+func (v *Video) PlayabilityError() error {
+	if v.Playable() {
+		return nil
+	}
+	return v.playability
+}
+
+// IsUnplayable returns true if the error was returned because a video
+// cannot be played, for example when it is private or has been removed.
+func IsUnplayable(err error) bool {
+	var ps *playabilityStatus
+	return errors.As(err, &ps)
+}
+
+// Qualities returns the quality labels of all the video streams from
+// highest to lowest quality.
+func (v *Video) Qualities() []string {
+	var (
+		seen      = make(map[string]bool)
+		streams   = append(append(Streams{}, v.Streams...), v.VideoStreams...)
+		qualities []string
+	)
+	sort.Stable(sort.Reverse(streams))
+	for _, s := range streams {
+		if s.QualityLabel == "" || seen[s.QualityLabel] {
+			continue
+		}
+		seen[s.QualityLabel] = true
+		qualities = append(qualities, s.QualityLabel)
+	}
+	return qualities
 }
 
 // Download will download the video given a file name.
 //
 // It is suggested that '.mp4' is used as the extension
 // in the file name but is not mandatory.
+//
+// Live streams are recorded until they end, see DownloadLive.
 func (v *Video) Download(fname string) error {
-	s := GetBestStream(v.Streams)
-	return DownloadFromStream(s, fname)
+	if v.IsLive && v.HLSManifestURL != "" {
+		return v.DownloadLive(fname, nil)
+	}
+	return DownloadFromStream(v.BestStream(), fname)
 }
 
 // DownloadAudio will download the video's audio given a file name.
 func (v *Video) DownloadAudio(fname string) error {
+	s := v.BestAudioStream()
+	if s == nil {
+		return errors.New("no audio streams")
+	}
+	return DownloadFromStream(s, fname)
+}
+
+// BestStream returns the stream used by Download.
+func (v *Video) BestStream() *Stream {
+	return GetBestStream(v.Streams)
+}
+
+// BestAudioStream returns the audio stream with the highest bitrate.
+// Returns nil if there are no audio streams.
+func (v *Video) BestAudioStream() *Stream {
 	var (
 		max  = 0
 		high *Stream
 	)
-
-	if len(v.AudioStreams) == 0 {
-		return errors.New("no audio streams")
-	}
-	for _, s := range v.AudioStreams {
+	for i, s := range v.AudioStreams {
 		if s.Bitrate > max {
-			high = &s
+			high = &v.AudioStreams[i]
 			max = s.Bitrate
 		}
 	}
-	return DownloadFromStream(high, fname)
+	return high
+}
+
+// Length returns the length of the video.
+func (v *Video) Length() time.Duration {
+	n, err := strconv.ParseInt(v.LengthSeconds, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n) * time.Second
 }
 
 // GetInfo returns a map of low-level video information used by youtube.
 func GetInfo(id string) (map[string][][]byte, error) {
-	r, err := info(id)
+	return DefaultClient.GetInfo(id)
+}
+
+// GetInfo returns a map of low-level video information used by youtube.
+func (c *Client) GetInfo(id string) (map[string][][]byte, error) {
+	r, err := c.info(id)
 	if err != nil {
 		return nil, err
 	}
@@ -110,18 +258,23 @@ func GetInfo(id string) (map[string][][]byte, error) {
 
 // Thumbnail is a video thumbnail
 type Thumbnail struct {
-	Height int
-	Width  int
-	URL    string
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+	URL    string `json:"url"`
+
+	client *Client
 }
 
 // Download will download the thumbnail to a file on disk
 func (t *Thumbnail) Download(filename string) error {
-	resp, err := client.Get(t.URL)
+	resp, err := clientOr(t.client).get(t.URL)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -131,6 +284,72 @@ func (t *Thumbnail) Download(filename string) error {
 	return err
 }
 
+// Ext returns the file extension of the thumbnail image.
+func (t *Thumbnail) Ext() string {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return ".jpg"
+	}
+	if ext := path.Ext(u.Path); ext != "" {
+		return ext
+	}
+	return ".jpg"
+}
+
+// BestThumbnail returns the largest thumbnail. Returns nil if the video has
+// no thumbnails.
+func (v *Video) BestThumbnail() *Thumbnail {
+	if len(v.Thumbnails) == 0 {
+		return nil
+	}
+	return &v.Thumbnails[len(v.Thumbnails)-1]
+}
+
+// ThumbnailFor returns the smallest thumbnail that is at least width by
+// height pixels, or the largest thumbnail if none of them are big enough.
+func (v *Video) ThumbnailFor(width, height int) *Thumbnail {
+	for i, t := range v.Thumbnails {
+		if t.Width >= width && t.Height >= height {
+			return &v.Thumbnails[i]
+		}
+	}
+	return v.BestThumbnail()
+}
+
+// static thumbnails that are hosted for every video but are
+// not always listed in the player response.
+var staticThumbnails = []Thumbnail{
+	{Width: 120, Height: 90, URL: "default.jpg"},
+	{Width: 320, Height: 180, URL: "mqdefault.jpg"},
+	{Width: 480, Height: 360, URL: "hqdefault.jpg"},
+	{Width: 640, Height: 480, URL: "sddefault.jpg"},
+	{Width: 1280, Height: 720, URL: "maxresdefault.jpg"},
+}
+
+// addStaticThumbnails adds the static thumbnails that are missing from
+// the list and sorts them by size.
+func addStaticThumbnails(id string, thumbs []Thumbnail) []Thumbnail {
+	have := make(map[string]bool, len(thumbs))
+	for _, t := range thumbs {
+		// thumbnails with a query string are resized versions of the
+		// static images so they don't count
+		if u, err := url.Parse(t.URL); err == nil && u.RawQuery == "" {
+			have[path.Base(u.Path)] = true
+		}
+	}
+	for _, t := range staticThumbnails {
+		if have[t.URL] {
+			continue
+		}
+		t.URL = fmt.Sprintf("https://i.ytimg.com/vi/%s/%s", id, t.URL)
+		thumbs = append(thumbs, t)
+	}
+	sort.SliceStable(thumbs, func(i, j int) bool {
+		return thumbs[i].Width*thumbs[i].Height < thumbs[j].Width*thumbs[j].Height
+	})
+	return thumbs
+}
+
 type inforeader struct {
 	*bufio.Reader
 	cleanup func() error
@@ -145,7 +364,7 @@ func (ir *inforeader) Close() error {
 	return ir.cleanup()
 }
 
-func info(id string) (byteReaderCloser, error) {
+func (c *Client) info(id string) (byteReaderCloser, error) {
 	var req = http.Request{
 		Method:     "GET",
 		Proto:      "HTTP/1.1",
@@ -160,7 +379,7 @@ func info(id string) (byteReaderCloser, error) {
 			RawQuery: url.Values{"video_id": {id}}.Encode(),
 		},
 	}
-	resp, err := client.Do(&req)
+	resp, err := c.do(&req)
 	if err != nil {
 		return nil, err
 	}
@@ -182,16 +401,27 @@ func initVideoData(in []byte, v *Video) (err error) {
 	v.baseVideo = vd.VideoDetails.baseVideo
 	v.Streams = vd.StreamingData.Formats
 	v.VideoStreams, v.AudioStreams = sortStreams(vd.StreamingData.AdaptiveFormats)
-	v.FileName = safeFileName(vd.VideoDetails.baseVideo.Title)
-	v.Thumbnails = vd.VideoDetails.Thumbnail.Thumbnails
-	if vd.PlayabilityStatus.Status != "OK" {
+	v.FileName = SafeFileName(vd.VideoDetails.baseVideo.Title)
+	v.Thumbnails = addStaticThumbnails(v.ID, vd.VideoDetails.Thumbnail.Thumbnails)
+	// prefer the markers on the progress bar over the description
+	if v.Chapters = vd.PlayerOverlays.chapters(v.Length()); v.Chapters == nil {
+		v.Chapters = parseChapters(v.Description, v.Length())
+	}
+	v.Keywords = vd.VideoDetails.Keywords
+	v.PublishDate = vd.Microformat.PlayerMicroformatRenderer.PublishDate
+	v.Category = vd.Microformat.PlayerMicroformatRenderer.Category
+	v.HLSManifestURL = vd.StreamingData.HLSManifestURL
+	v.DASHManifestURL = vd.StreamingData.DASHManifestURL
+	v.ScheduledStart = vd.Microformat.PlayerMicroformatRenderer.LiveBroadcastDetails.StartTimestamp
+	v.playability = vd.PlayabilityStatus
+	if vd.PlayabilityStatus != nil && vd.PlayabilityStatus.Status != "OK" {
 		err = vd.PlayabilityStatus
 	}
 	return err
 }
 
-func videoDataFromHTML(id string) (*ytConfig, error) {
-	resp, err := http.Get(fmt.Sprintf("https://%s/watch?v=%s", host, id))
+func (c *Client) videoDataFromHTML(id string) (*ytConfig, error) {
+	resp, err := c.get(fmt.Sprintf("https://%s/watch?v=%s", host, id))
 	if err != nil {
 		return nil, err
 	}
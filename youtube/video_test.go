@@ -122,7 +122,7 @@ func TestVideo_Err(t *testing.T) {
 }
 
 func TestInfo(t *testing.T) {
-	r, err := info("O9Ks3_8Nq1s")
+	r, err := DefaultClient.info("O9Ks3_8Nq1s")
 	if err != nil {
 		t.Error(err)
 	}
@@ -153,9 +153,9 @@ func TestGetFromHTML(t *testing.T) {
 
 func TestSafeFileName(t *testing.T) {
 	origin := `\/:*?"<>|.`
-	safe := safeFileName(origin)
+	safe := SafeFileName(origin)
 	if safe != "" {
-		t.Errorf(`'safeFileName' is not getting rid of the right characters. Expected: "", got: "%s"`, safe)
+		t.Errorf(`'SafeFileName' is not getting rid of the right characters. Expected: "", got: "%s"`, safe)
 	}
 }
 
@@ -183,3 +183,29 @@ func temp() string {
 	}
 	return f.Name()
 }
+
+func TestThumbnails(t *testing.T) {
+	v := &Video{}
+	v.ID = "O9Ks3_8Nq1s"
+	v.Thumbnails = addStaticThumbnails(v.ID, []Thumbnail{
+		{Width: 168, Height: 94, URL: "https://i.ytimg.com/vi/O9Ks3_8Nq1s/hqdefault.jpg?sqp=-oaymwEY"},
+		{Width: 336, Height: 188, URL: "https://i.ytimg.com/vi/O9Ks3_8Nq1s/hqdefault.jpg?sqp=-oaymwEZ"},
+		{Width: 640, Height: 480, URL: "https://i.ytimg.com/vi/O9Ks3_8Nq1s/sddefault.jpg"},
+	})
+	if len(v.Thumbnails) != 7 {
+		t.Fatalf("wrong number of thumbnails: got %d, want 7", len(v.Thumbnails))
+	}
+	best := v.BestThumbnail()
+	if best.URL != "https://i.ytimg.com/vi/O9Ks3_8Nq1s/maxresdefault.jpg" {
+		t.Errorf("wrong best thumbnail: %s", best.URL)
+	}
+	if th := v.ThumbnailFor(320, 180); th.Width != 320 || th.Height != 180 {
+		t.Errorf("wrong thumbnail for 320x180: got %dx%d", th.Width, th.Height)
+	}
+	if th := v.ThumbnailFor(4000, 4000); th != best {
+		t.Error("should fall back to the largest thumbnail")
+	}
+	if ext := best.Ext(); ext != ".jpg" {
+		t.Errorf("wrong extension: %s", ext)
+	}
+}